@@ -0,0 +1,253 @@
+package gettext
+
+//go:generate sh -c "python3 gencharsets.py > charset_tables.go"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrUnknownCharset is wrapped by the errors returned for mo files in a
+// charset no Decoder is registered for.
+var ErrUnknownCharset = errors.New("unknown charset")
+
+// Decoder converts text in some charset to UTF-8.
+type Decoder func(text []byte) (string, error)
+
+var charsets = struct {
+	sync.RWMutex
+	decoders map[string]Decoder
+}{decoders: map[string]Decoder{}}
+
+// RegisterCharset makes mo files in the named charset readable. Charset names
+// are matched ignoring case, "-" and "_", so registering "Shift_JIS" also
+// covers "shift-jis". The ISO-8859, KOI8-R, KOI8-U, Windows-125x, EUC-JP and
+// Shift_JIS charsets are registered by default.
+func RegisterCharset(name string, decoder Decoder) {
+	charsets.Lock()
+	defer charsets.Unlock()
+	charsets.decoders[charset_key(name)] = decoder
+}
+
+func charset_key(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// charset_decoder returns the Decoder for charset, or nil if text in charset
+// is UTF-8 already. A missing charset, and the "CHARSET" placeholder of po
+// templates, are taken to be UTF-8, like msgfmt does.
+func charset_decoder(charset string) (Decoder, error) {
+	key := charset_key(charset)
+	switch key {
+	case "", "charset", "utf8", "ascii", "usascii":
+		return nil, nil
+	}
+	charsets.RLock()
+	decoder, ok := charsets.decoders[key]
+	charsets.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownCharset, charset)
+	}
+	return decoder, nil
+}
+
+// header_charset returns the charset of the Content-Type field of a raw mo
+// file header.
+func header_charset(info string) string {
+	for _, line := range strings.Split(info, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "content-type") {
+			continue
+		}
+		return content_type_charset(v)
+	}
+	return ""
+}
+
+// content_type_charset returns the charset parameter of a Content-Type.
+func content_type_charset(content_type string) string {
+	_, charset, ok := strings.Cut(content_type, "charset=")
+	if !ok {
+		return ""
+	}
+	charset, _, _ = strings.Cut(charset, ";")
+	return strings.TrimSpace(charset)
+}
+
+// decode_messages converts the keys and msgstrs of messages to UTF-8
+// according to the charset declared in their header.
+func decode_messages(messages []mo_message) error {
+	charset := ""
+	for _, message := range messages {
+		if message.key == "" {
+			charset = header_charset(message.str)
+		}
+	}
+	decoder, err := charset_decoder(charset)
+	if decoder == nil {
+		return err
+	}
+	for i := range messages {
+		key, err := decoder([]byte(messages[i].key))
+		if err != nil {
+			return fmt.Errorf("decoding %s: %w", charset, err)
+		}
+		str, err := decoder([]byte(messages[i].str))
+		if err != nil {
+			return fmt.Errorf("decoding %s: %w", charset, err)
+		}
+		messages[i] = mo_message{key: key, str: str}
+	}
+	return nil
+}
+
+// single_byte_decoder decodes ASCII compatible single byte charsets.
+func single_byte_decoder(table *[128]rune) Decoder {
+	return func(text []byte) (string, error) {
+		var b strings.Builder
+		b.Grow(len(text))
+		for _, c := range text {
+			if c < 0x80 {
+				b.WriteByte(c)
+				continue
+			}
+			r := table[c-0x80]
+			if r == 0 {
+				return "", fmt.Errorf("undefined byte 0x%02x", c)
+			}
+			b.WriteRune(r)
+		}
+		return b.String(), nil
+	}
+}
+
+// jis returns the rune at row and cell, both starting at 0, of a JIS table.
+func jis(table *[94 * 94]uint16, row byte, cell byte) (rune, bool) {
+	if row >= 94 || cell >= 94 || table[int(row)*94+int(cell)] == 0 {
+		return utf8.RuneError, false
+	}
+	return rune(table[int(row)*94+int(cell)]), true
+}
+
+// DecodeEUCJP decodes EUC-JP, including half width katakana and JIS X 0212.
+func DecodeEUCJP(text []byte) (string, error) {
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(text); i++ {
+		c, start := text[i], i
+		var r rune
+		ok := true
+		switch {
+		case c < 0x80:
+			r = rune(c)
+		case c == 0x8e && i+1 < len(text) && text[i+1] >= 0xa1 && text[i+1] <= 0xdf:
+			r = 0xff61 + rune(text[i+1]-0xa1)
+			i++
+		case c == 0x8f && i+2 < len(text) && text[i+1] >= 0xa1 && text[i+2] >= 0xa1:
+			r, ok = jis(&jisx0212, text[i+1]-0xa1, text[i+2]-0xa1)
+			i += 2
+		case c >= 0xa1 && i+1 < len(text) && text[i+1] >= 0xa1:
+			r, ok = jis(&jisx0208, c-0xa1, text[i+1]-0xa1)
+			i++
+		default:
+			ok = false
+		}
+		if !ok {
+			return "", fmt.Errorf("invalid EUC-JP sequence at byte %d", start)
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+// DecodeShiftJIS decodes Shift_JIS, the JIS X 0208 characters and half width
+// katakana. Vendor extensions such as those of Windows code page 932 are not
+// supported.
+func DecodeShiftJIS(text []byte) (string, error) {
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(text); i++ {
+		c, start := text[i], i
+		var r rune
+		ok := true
+		switch {
+		case c < 0x80:
+			r = rune(c)
+		case c >= 0xa1 && c <= 0xdf:
+			r = 0xff61 + rune(c-0xa1)
+		case (c >= 0x81 && c <= 0x9f || c >= 0xe0 && c <= 0xef) && i+1 < len(text):
+			trail := text[i+1]
+			row := (c - 0x81) * 2
+			if c >= 0xe0 {
+				row = (c - 0xc1) * 2
+			}
+			switch {
+			case trail >= 0x9f && trail <= 0xfc:
+				r, ok = jis(&jisx0208, row+1, trail-0x9f)
+			case trail >= 0x40 && trail <= 0x7e:
+				r, ok = jis(&jisx0208, row, trail-0x40)
+			case trail >= 0x80 && trail < 0x9f:
+				r, ok = jis(&jisx0208, row, trail-0x41)
+			default:
+				ok = false
+			}
+			i++
+		default:
+			ok = false
+		}
+		if !ok {
+			return "", fmt.Errorf("invalid Shift_JIS sequence at byte %d", start)
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+var single_byte_aliases = map[string]string{
+	"latin1":   "iso88591",
+	"latin2":   "iso88592",
+	"latin3":   "iso88593",
+	"latin4":   "iso88594",
+	"cyrillic": "iso88595",
+	"arabic":   "iso88596",
+	"greek":    "iso88597",
+	"hebrew":   "iso88598",
+	"latin5":   "iso88599",
+	"latin6":   "iso885910",
+	"latin7":   "iso885913",
+	"latin8":   "iso885914",
+	"latin9":   "iso885915",
+	"latin10":  "iso885916",
+	"cp1250":   "windows1250",
+	"cp1251":   "windows1251",
+	"cp1252":   "windows1252",
+	"cp1253":   "windows1253",
+	"cp1254":   "windows1254",
+	"cp1255":   "windows1255",
+	"cp1256":   "windows1256",
+	"cp1257":   "windows1257",
+	"cp1258":   "windows1258",
+}
+
+func init() {
+	for name, table := range single_byte_charsets {
+		RegisterCharset(name, single_byte_decoder(table))
+	}
+	for alias, name := range single_byte_aliases {
+		RegisterCharset(alias, single_byte_decoder(single_byte_charsets[name]))
+	}
+	for _, name := range []string{"EUC-JP", "eucJP", "ujis"} {
+		RegisterCharset(name, DecodeEUCJP)
+	}
+	for _, name := range []string{"Shift_JIS", "SJIS", "MS_Kanji"} {
+		RegisterCharset(name, DecodeShiftJIS)
+	}
+}
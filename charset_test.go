@@ -0,0 +1,119 @@
+package gettext
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// legacy_catalog returns a mo file in charset with a translation of
+// "greeting" and a plural message.
+func legacy_catalog(charset string, greeting string, plural string) []byte {
+	return encode_mo([]mo_message{
+		{key: "", str: "Last-Translator: \xe9\xd7\xc1\xce \xf0\xc5\xd4\xd2\xcf\xd7\nContent-Type: text/plain; charset=" + charset + "\n"},
+		{key: "greeting", str: greeting},
+		{key: "%d file\x00%d files", str: plural},
+	}, MOOptions{})
+}
+
+func TestParseMOCharset(t *testing.T) {
+	data := legacy_catalog("KOI8-R", "\xf0\xd2\xc9\xd7\xc5\xd4", "\xb3\xcc\xcb\xc1\x00\xb3\xcc\xcb\xc9")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "messages.mo")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, parse := range map[string]func() (Catalog, error){
+		"ParseMOBytes": func() (Catalog, error) { return ParseMOBytes(data) },
+		"ParseMOLazy":  func() (Catalog, error) { return ParseMOLazy(data) },
+		"OpenMO":       func() (Catalog, error) { return OpenMO(path) },
+	} {
+		catalog, err := parse()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		assert_equal(t, catalog.Gettext("greeting"), "Привет")
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 2), "Ёлки")
+		assert_equal(t, catalog.Header("Last-Translator"), "Иван Петров")
+		assert_equal(t, catalog.Charset(), "KOI8-R")
+	}
+}
+
+func TestParseMOCharsetErrors(t *testing.T) {
+	_, err := ParseMOBytes(legacy_catalog("x-klingon", "", ""))
+	if !errors.Is(err, ErrUnknownCharset) || errors.Is(err, ErrCorruptCatalog) {
+		t.Errorf("expected an unknown charset error, got %v", err)
+	}
+	_, err = ParseMOLazy(legacy_catalog("x-klingon", "", ""))
+	if !errors.Is(err, ErrUnknownCharset) {
+		t.Errorf("expected an unknown charset error, got %v", err)
+	}
+	// 0x81 is not defined in Windows-1252
+	_, err = ParseMOBytes(legacy_catalog("windows-1252", "Gr\xfc\xdfe \x81", ""))
+	if !errors.Is(err, ErrCorruptCatalog) {
+		t.Errorf("expected a corrupt catalog error, got %v", err)
+	}
+}
+
+func TestCharsetNames(t *testing.T) {
+	for _, charset := range []string{"", "CHARSET", "UTF-8", "utf8", "ASCII"} {
+		decoder, err := charset_decoder(charset)
+		if decoder != nil || err != nil {
+			t.Errorf("%q: expected no decoding", charset)
+		}
+	}
+	for _, charset := range []string{"ISO-8859-15", "iso_8859_15", "latin9", "Latin-9"} {
+		decoder, err := charset_decoder(charset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := decoder([]byte("\xa4 10"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_equal(t, s, "€ 10")
+	}
+	assert_equal(t, header_charset("Content-Type: text/plain; charset=CP1251; format=flowed\n"), "CP1251")
+	assert_equal(t, header_charset("Content-Type: text/plain\n"), "")
+}
+
+func TestRegisterCharset(t *testing.T) {
+	RegisterCharset("x-arrows", func(text []byte) (string, error) {
+		return strings.ReplaceAll(string(text), "\x01", "→"), nil
+	})
+	catalog, err := ParseMOBytes(legacy_catalog("X_ARROWS", "\x01 hello", "one\x00\x01 many"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("greeting"), "→ hello")
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 2), "→ many")
+}
+
+func TestDecodeJapanese(t *testing.T) {
+	for _, test := range []struct {
+		decoder  Decoder
+		text     string
+		expected string
+	}{
+		{DecodeShiftJIS, "\xb6\xc0\xb6\xc5 \x82\xa9\x82\xc8 \x8a\xbf\x8e\x9a", "ｶﾀｶﾅ かな 漢字"},
+		{DecodeEUCJP, "\x8e\xb6\x8e\xc0\x8e\xb6\x8e\xc5 \xa4\xab\xa4\xca \xb4\xc1\xbb\xfa \x8f\xb0\xa1", "ｶﾀｶﾅ かな 漢字 丂"},
+	} {
+		s, err := test.decoder([]byte(test.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_equal(t, s, test.expected)
+	}
+	for _, text := range []string{"\x82", "\x82\x20", "\xf0\x40", "\x80"} {
+		if _, err := DecodeShiftJIS([]byte(text)); err == nil {
+			t.Errorf("expected %q to be invalid Shift_JIS", text)
+		}
+	}
+	for _, text := range []string{"\xa4", "\xa4\x20", "\x8e\xe0", "\x8f\xa1", "\xa9\xa1"} {
+		if _, err := DecodeEUCJP([]byte(text)); err == nil {
+			t.Errorf("expected %q to be invalid EUC-JP", text)
+		}
+	}
+}
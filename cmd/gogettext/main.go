@@ -0,0 +1,377 @@
+// Command gogettext manages gettext catalogs without GNU gettext installed.
+//
+// Usage:
+//
+//	gogettext msgfmt [-o file.mo] [-endian little|big] [-no-hash] file.po
+//	gogettext msgunfmt [-o file.po] file.mo
+//	gogettext header file.mo|file.po
+//	gogettext list file.mo|file.po
+//	gogettext plural [-e expression] [file.mo|file.po] n...
+//	gogettext msgmerge [-o file.po | -U] [-N] def.po ref.pot
+//	gogettext xgettext [-o file.pot] [-k keyword]... [-add-comments tag] file.go|dir...
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ojii/gettext.go"
+	"github.com/ojii/gettext.go/extract"
+	"github.com/ojii/gettext.go/pluralforms"
+)
+
+type command struct {
+	run   func(args []string, stdout io.Writer) error
+	usage string
+}
+
+var commands = map[string]command{
+	"msgfmt":   {msgfmt, "compile a po file to a mo file"},
+	"msgunfmt": {msgunfmt, "decompile a mo file to a po file"},
+	"header":   {header, "print the header of a catalog"},
+	"list":     {list, "list the messages of a catalog"},
+	"plural":   {plural, "evaluate the plural forms expression of a catalog"},
+	"xgettext": {xgettext, "extract translatable strings from Go files"},
+	"msgmerge": {msgmerge, "update a po file from a new template"},
+}
+
+var command_order = []string{"msgfmt", "msgunfmt", "header", "list", "plural", "xgettext", "msgmerge"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "gogettext: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	err := cmd.run(args[1:], stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gogettext %s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gogettext <command> [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, name := range command_order {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+func new_flag_set(name string) *flag.FlagSet {
+	return flag.NewFlagSet("gogettext "+name, flag.ContinueOnError)
+}
+
+// read_catalog reads a po or mo file, telling them apart by the mo magic.
+func read_catalog(path string) (*gettext.POFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if is_mo(data) {
+		return gettext.DecompileMO(data)
+	}
+	return gettext.ParsePO(bytes.NewReader(data))
+}
+
+func is_mo(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	magic := binary.LittleEndian.Uint32(data)
+	return magic == 0x950412de || magic == 0xde120495
+}
+
+// write_output writes to path, or stdout if path is empty or "-".
+func write_output(path string, stdout io.Writer, write func(w io.Writer) error) error {
+	if path == "" || path == "-" {
+		return write(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func msgfmt(args []string, stdout io.Writer) error {
+	flags := new_flag_set("msgfmt")
+	output := flags.String("o", "messages.mo", "output file, - for stdout")
+	endian := flags.String("endian", "little", "byte order of the mo file, little or big")
+	no_hash := flags.Bool("no-hash", false, "leave out the hash table")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one po file")
+	}
+	options := gettext.MOOptions{NoHashTable: *no_hash}
+	switch *endian {
+	case "little":
+		options.ByteOrder = binary.LittleEndian
+	case "big":
+		options.ByteOrder = binary.BigEndian
+	default:
+		return fmt.Errorf("invalid byte order %q", *endian)
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	po, err := gettext.ParsePO(f)
+	if err != nil {
+		return err
+	}
+	return write_output(*output, stdout, func(w io.Writer) error {
+		return gettext.WriteMO(w, po, options)
+	})
+}
+
+func msgunfmt(args []string, stdout io.Writer) error {
+	flags := new_flag_set("msgunfmt")
+	output := flags.String("o", "-", "output file, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one mo file")
+	}
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	po, err := gettext.DecompileMO(data)
+	if err != nil {
+		return err
+	}
+	return write_output(*output, stdout, func(w io.Writer) error {
+		_, err := po.WriteTo(w)
+		return err
+	})
+}
+
+func header(args []string, stdout io.Writer) error {
+	flags := new_flag_set("header")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one catalog")
+	}
+	po, err := read_catalog(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if po.Header == nil || len(po.Header.Str) == 0 {
+		return errors.New("catalog has no header")
+	}
+	_, err = io.WriteString(stdout, po.Header.Str[0])
+	return err
+}
+
+func list(args []string, stdout io.Writer) error {
+	flags := new_flag_set("list")
+	untranslated := flags.Bool("untranslated", false, "only list untranslated messages")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one catalog")
+	}
+	po, err := read_catalog(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, entry := range po.Entries {
+		if entry.Obsolete || (*untranslated && entry.Translated()) {
+			continue
+		}
+		fields := []string{}
+		if entry.HasContext {
+			fields = append(fields, "msgctxt "+strconv.Quote(entry.Context))
+		}
+		fields = append(fields, "msgid "+strconv.Quote(entry.ID))
+		if entry.Plural() {
+			fields = append(fields, "msgid_plural "+strconv.Quote(entry.IDPlural))
+		}
+		for i, s := range entry.Str {
+			if entry.Plural() {
+				fields = append(fields, fmt.Sprintf("msgstr[%d] %s", i, strconv.Quote(s)))
+			} else {
+				fields = append(fields, "msgstr "+strconv.Quote(s))
+			}
+		}
+		if len(entry.Flags) != 0 {
+			fields = append(fields, "#, "+strings.Join(entry.Flags, ", "))
+		}
+		fmt.Fprintln(stdout, strings.Join(fields, "\t"))
+	}
+	return nil
+}
+
+func plural(args []string, stdout io.Writer) error {
+	flags := new_flag_set("plural")
+	expression := flags.String("e", "", "plural expression or Plural-Forms header to use instead of the one of a catalog")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	numbers := flags.Args()
+	var forms gettext.PluralForms
+	var err error
+	switch {
+	case *expression == "":
+		if len(numbers) == 0 {
+			return errors.New("expected a catalog or -e")
+		}
+		po, err := read_catalog(numbers[0])
+		if err != nil {
+			return err
+		}
+		numbers = numbers[1:]
+		header := po.HeaderField("Plural-Forms")
+		if header == "" {
+			return errors.New("catalog has no plural forms expression")
+		}
+		forms, err = gettext.ParsePluralForms(header)
+		if err != nil {
+			return err
+		}
+	case strings.Contains(*expression, "plural="):
+		forms, err = gettext.ParsePluralForms(*expression)
+	default:
+		forms.Expression, err = pluralforms.Compile(*expression)
+	}
+	if err != nil {
+		return err
+	}
+	if len(numbers) == 0 {
+		return errors.New("expected at least one n")
+	}
+	for _, s := range numbers {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid n %q", s)
+		}
+		index := forms.Expression.Eval(uint32(n))
+		if forms.NPlurals != 0 && (index < 0 || index >= forms.NPlurals) {
+			return fmt.Errorf("n = %d selects form %d, but nplurals=%d", n, index, forms.NPlurals)
+		}
+		fmt.Fprintf(stdout, "%d\t%d\n", n, index)
+	}
+	return nil
+}
+
+// keywords collects repeated -k flags.
+type keywords []extract.Keyword
+
+func (k *keywords) String() string {
+	return fmt.Sprint(*k)
+}
+
+func (k *keywords) Set(spec string) error {
+	keyword, err := extract.ParseKeyword(spec)
+	if err != nil {
+		return err
+	}
+	*k = append(*k, keyword)
+	return nil
+}
+
+func xgettext(args []string, stdout io.Writer) error {
+	flags := new_flag_set("xgettext")
+	output := flags.String("o", "messages.pot", "output file, - for stdout")
+	var extra keywords
+	flags.Var(&extra, "k", "additional keyword, such as T or T:1c,2 (repeatable)")
+	no_default := flags.Bool("no-default-keywords", false, "only use the keywords given with -k")
+	tag := flags.String("add-comments", "TRANSLATORS:", "copy comments starting with this tag, empty for none")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("expected at least one Go file or directory")
+	}
+	extractor := extract.NewExtractor()
+	extractor.CommentTag = *tag
+	if !*no_default {
+		extractor.Keywords = append(extractor.Keywords, extract.DefaultKeywords...)
+	}
+	extractor.Keywords = append(extractor.Keywords, extra...)
+	if len(extractor.Keywords) == 0 {
+		return errors.New("no keywords")
+	}
+	for _, path := range flags.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = extractor.AddDir(path)
+		} else {
+			err = extractor.AddFile(path, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	pot := extractor.Template()
+	pot.SetHeaderField("POT-Creation-Date", time.Now().Format("2006-01-02 15:04-0700"))
+	return write_output(*output, stdout, func(w io.Writer) error {
+		_, err := pot.WriteTo(w)
+		return err
+	})
+}
+
+func msgmerge(args []string, stdout io.Writer) error {
+	flags := new_flag_set("msgmerge")
+	output := flags.String("o", "-", "output file, - for stdout")
+	update := flags.Bool("U", false, "update def.po in place")
+	no_fuzzy := flags.Bool("N", false, "do not use fuzzy matching")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("expected def.po and ref.pot")
+	}
+	def, err := read_catalog(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	ref, err := read_catalog(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	merged := gettext.Merge(def, ref, gettext.MergeOptions{NoFuzzyMatching: *no_fuzzy})
+	if *update {
+		*output = flags.Arg(0)
+	}
+	return write_output(*output, stdout, func(w io.Writer) error {
+		_, err := merged.WriteTo(w)
+		return err
+	})
+}
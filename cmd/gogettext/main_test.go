@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run_ok(t *testing.T, args ...string) string {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("%v exited with %d: %s", args, code, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestMsgfmt(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "messages.mo")
+	run_ok(t, "msgfmt", "-o", output, "../../testdata/en/messages.po")
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile("../../testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Error("compiled mo file differs from testdata")
+	}
+	big := run_ok(t, "msgfmt", "-o", "-", "-endian", "big", "-no-hash", "../../testdata/en/messages.po")
+	if !strings.HasPrefix(big, "\x95\x04\x12\xde") {
+		t.Errorf("expected big endian magic, got %q", big[:4])
+	}
+}
+
+func TestMsgunfmt(t *testing.T) {
+	got := run_ok(t, "msgunfmt", "../../testdata/ja/messages.mo")
+	expected, err := os.ReadFile("../../testdata/ja/messages.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	for _, path := range []string{"../../testdata/en/messages.mo", "../../testdata/en/messages.po"} {
+		got := run_ok(t, "header", path)
+		if !strings.Contains(got, "Plural-Forms: nplurals=2; plural=(n != 1);\n") {
+			t.Errorf("%s: unexpected header %q", path, got)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	got := run_ok(t, "list", "../../testdata/fr/messages.mo")
+	for _, line := range []string{
+		"msgctxt \"verb\"\tmsgid \"Open\"\tmsgstr \"Ouvrir\"\n",
+		"msgid \"greeting\"\tmsgstr \"Bonjour\"\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("expected %q in %q", line, got)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	got := run_ok(t, "plural", "../../testdata/fr/messages.mo", "0", "1", "2")
+	if got != "0\t0\n1\t0\n2\t1\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+	got = run_ok(t, "plural", "-e", "n==1?0:n==2?1:2", "1", "2", "3")
+	if got != "1\t0\n2\t1\n3\t2\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+	got = run_ok(t, "plural", "-e", "nplurals=2; plural=n>1;", "1", "2")
+	if got != "1\t0\n2\t1\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"bogus"},
+		{"msgfmt"},
+		{"msgunfmt", "../../testdata/en/messages.po"},
+		{"plural", "-e", "n!=1", "x"},
+		{"plural", "-e", "nplurals=2; plural=n>2000?5:n!=1;", "3000"},
+		{"plural", "-e", "nplurals=2; plural=n%3;", "1"},
+		{"header", "does-not-exist.mo"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if run(args, &stdout, &stderr) == 0 {
+			t.Errorf("%v: expected a non-zero exit code", args)
+		}
+	}
+}
+
+func TestXgettext(t *testing.T) {
+	dir := t.TempDir()
+	source := "package main\n\nfunc main() {\n\t// TRANSLATORS: a greeting\n\tprintln(c.Gettext(\"Hello\"), T(\"World\"))\n}\n"
+	err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(source), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := run_ok(t, "xgettext", "-o", "-", "-k", "T", dir)
+	for _, snippet := range []string{
+		"#. TRANSLATORS: a greeting\n#: " + filepath.ToSlash(filepath.Join(dir, "main.go")) + ":5\nmsgid \"Hello\"\nmsgstr \"\"\n",
+		"msgid \"World\"\n",
+		"\"POT-Creation-Date: 20",
+	} {
+		if !strings.Contains(got, snippet) {
+			t.Errorf("expected %q in %q", snippet, got)
+		}
+	}
+}
+
+func TestMsgmerge(t *testing.T) {
+	dir := t.TempDir()
+	def := filepath.Join(dir, "de.po")
+	ref := filepath.Join(dir, "messages.pot")
+	err := os.WriteFile(def, []byte("msgid \"Hello\"\nmsgstr \"Hallo\"\n\nmsgid \"Bye\"\nmsgstr \"Tschüss\"\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(ref, []byte("msgid \"Hello\"\nmsgstr \"\"\n\nmsgid \"Hello!\"\nmsgstr \"\"\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "msgid \"Hello\"\nmsgstr \"Hallo\"\n\n" +
+		"#, fuzzy\n#| msgid \"Hello\"\nmsgid \"Hello!\"\nmsgstr \"Hallo\"\n\n" +
+		"#~ msgid \"Bye\"\n#~ msgstr \"Tschüss\"\n"
+	got := run_ok(t, "msgmerge", def, ref)
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	run_ok(t, "msgmerge", "-U", def, ref)
+	updated, err := os.ReadFile(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(updated) != expected {
+		t.Errorf("expected %q, got %q", expected, updated)
+	}
+}
@@ -0,0 +1,311 @@
+// Package extract finds translatable strings in Go source code and turns them
+// into a po template, like xgettext does for C.
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ojii/gettext.go"
+)
+
+// Keyword describes a function or method whose calls mark translatable
+// strings. Argument positions start at 1, zero means there is no such
+// argument.
+type Keyword struct {
+	Name    string
+	ID      int
+	Plural  int
+	Context int
+}
+
+// DefaultKeywords match the methods of gettext.Catalog and gettext.Domains.
+var DefaultKeywords = []Keyword{
+	{Name: "Gettext", ID: 1},
+	{Name: "NGettext", ID: 1, Plural: 2},
+	{Name: "PGettext", Context: 1, ID: 2},
+	{Name: "NPGettext", Context: 1, ID: 2, Plural: 3},
+	{Name: "Lookup", ID: 1},
+	{Name: "NLookup", ID: 1, Plural: 2},
+	{Name: "PLookup", Context: 1, ID: 2},
+	{Name: "NPLookup", Context: 1, ID: 2, Plural: 3},
+	{Name: "DGettext", ID: 2},
+	{Name: "DNGettext", ID: 2, Plural: 3},
+	{Name: "DPGettext", Context: 2, ID: 3},
+	{Name: "DNPGettext", Context: 2, ID: 3, Plural: 4},
+}
+
+// ParseKeyword parses a keyword in the format of xgettext's --keyword option:
+// "Name" (the msgid is the first argument), "Name:2" (the msgid is the second
+// argument), "Name:1,2" (msgid and plural) or "Name:1c,2,3" (context, msgid
+// and plural).
+func ParseKeyword(spec string) (Keyword, error) {
+	name, args, ok := strings.Cut(spec, ":")
+	keyword := Keyword{Name: name, ID: 1}
+	if name == "" {
+		return keyword, fmt.Errorf("invalid keyword %q: missing name", spec)
+	}
+	if !ok {
+		return keyword, nil
+	}
+	keyword.ID = 0
+	for _, arg := range strings.Split(args, ",") {
+		context := strings.HasSuffix(arg, "c")
+		position, err := strconv.Atoi(strings.TrimSuffix(arg, "c"))
+		if err != nil || position < 1 {
+			return keyword, fmt.Errorf("invalid keyword %q: bad argument %q", spec, arg)
+		}
+		switch {
+		case context && keyword.Context == 0:
+			keyword.Context = position
+		case !context && keyword.ID == 0:
+			keyword.ID = position
+		case !context && keyword.Plural == 0:
+			keyword.Plural = position
+		default:
+			return keyword, fmt.Errorf("invalid keyword %q: too many arguments", spec)
+		}
+	}
+	if keyword.ID == 0 {
+		return keyword, fmt.Errorf("invalid keyword %q: missing msgid argument", spec)
+	}
+	return keyword, nil
+}
+
+// Extractor collects the translatable strings of Go files. The zero value
+// uses the DefaultKeywords and copies no comments.
+type Extractor struct {
+	// Keywords to look for, DefaultKeywords if nil.
+	Keywords []Keyword
+	// CommentTag marks the comments preceding a keyword that are copied to
+	// the template for translators. Set it to "" to copy no comments.
+	CommentTag string
+
+	fset    *token.FileSet
+	entries map[string]*gettext.POEntry
+	order   []*gettext.POEntry
+}
+
+// NewExtractor returns an Extractor using the DefaultKeywords that copies
+// comments starting with "TRANSLATORS:".
+func NewExtractor() *Extractor {
+	return &Extractor{
+		CommentTag: "TRANSLATORS:",
+		fset:       token.NewFileSet(),
+		entries:    map[string]*gettext.POEntry{},
+	}
+}
+
+// AddDir extracts the strings of all Go files below root, skipping test files
+// and the directories the go tool ignores.
+func (e *Extractor) AddDir(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		return e.AddFile(path, nil)
+	})
+}
+
+// AddFile extracts the strings of a Go file. If src is nil, the file is read
+// from filename, otherwise src is used as its contents.
+func (e *Extractor) AddFile(filename string, src []byte) error {
+	var source interface{}
+	if src != nil {
+		source = src
+	}
+	if e.fset == nil {
+		e.fset = token.NewFileSet()
+	}
+	file, err := parser.ParseFile(e.fset, filename, source, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	keywords := e.Keywords
+	if keywords == nil {
+		keywords = DefaultKeywords
+	}
+	by_name := map[string]Keyword{}
+	for _, keyword := range keywords {
+		by_name[keyword.Name] = keyword
+	}
+	comments := map[int]*ast.CommentGroup{}
+	for _, group := range file.Comments {
+		comments[e.fset.Position(group.End()).Line] = group
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		keyword, ok := by_name[call_name(call)]
+		if !ok {
+			return true
+		}
+		position := e.fset.Position(call.Pos())
+		entry := &gettext.POEntry{}
+		if !string_arg(call, keyword.ID, &entry.ID) ||
+			(keyword.Plural != 0 && !string_arg(call, keyword.Plural, &entry.IDPlural)) ||
+			(keyword.Context != 0 && !string_arg(call, keyword.Context, &entry.Context)) {
+			return true
+		}
+		entry.HasContext = keyword.Context != 0
+		e.add(entry, fmt.Sprintf("%s:%d", filepath.ToSlash(position.Filename), position.Line), e.comment(comments, position.Line))
+		return true
+	})
+	return nil
+}
+
+// comment returns the lines of the tagged comment directly preceding line.
+func (e *Extractor) comment(comments map[int]*ast.CommentGroup, line int) []string {
+	if e.CommentTag == "" {
+		return nil
+	}
+	group, ok := comments[line-1]
+	if !ok {
+		if group, ok = comments[line]; !ok {
+			return nil
+		}
+	}
+	text := group.Text()
+	index := strings.Index(text, e.CommentTag)
+	if index == -1 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(text[index:]), "\n")
+}
+
+func (e *Extractor) add(entry *gettext.POEntry, reference string, comments []string) {
+	key := entry.Key()
+	if e.entries == nil {
+		e.entries = map[string]*gettext.POEntry{}
+	}
+	existing, ok := e.entries[key]
+	if !ok {
+		entry.Str = []string{""}
+		if entry.Plural() {
+			entry.Str = []string{"", ""}
+		}
+		e.entries[key] = entry
+		e.order = append(e.order, entry)
+		existing = entry
+	} else if !existing.Plural() && entry.Plural() {
+		existing.IDPlural = entry.IDPlural
+		existing.Str = []string{"", ""}
+	}
+	existing.References = append(existing.References, reference)
+	for _, comment := range comments {
+		if !contains(existing.ExtractedComments, comment) {
+			existing.ExtractedComments = append(existing.ExtractedComments, comment)
+		}
+	}
+}
+
+// Template returns a po template with all strings extracted so far, in the
+// order they were first found.
+func (e *Extractor) Template() *gettext.POFile {
+	po := &gettext.POFile{
+		Header: &gettext.POEntry{
+			TranslatorComments: []string{
+				"SOME DESCRIPTIVE TITLE.",
+				"Copyright (C) YEAR THE PACKAGE'S COPYRIGHT HOLDER",
+				"This file is distributed under the same license as the PACKAGE package.",
+				"FIRST AUTHOR <EMAIL@ADDRESS>, YEAR.",
+				"",
+			},
+			Flags: []string{"fuzzy"},
+		},
+		Entries: make([]*gettext.POEntry, len(e.order)),
+	}
+	for _, field := range [][2]string{
+		{"Project-Id-Version", "PACKAGE VERSION"},
+		{"Report-Msgid-Bugs-To", ""},
+		{"POT-Creation-Date", "YEAR-MO-DA HO:MI+ZONE"},
+		{"PO-Revision-Date", "YEAR-MO-DA HO:MI+ZONE"},
+		{"Last-Translator", "FULL NAME <EMAIL@ADDRESS>"},
+		{"Language-Team", "LANGUAGE <LL@li.org>"},
+		{"Language", ""},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=UTF-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+		{"Plural-Forms", "nplurals=INTEGER; plural=EXPRESSION;"},
+	} {
+		po.SetHeaderField(field[0], field[1])
+	}
+	copy(po.Entries, e.order)
+	return po
+}
+
+// call_name returns the name of the called function or method.
+func call_name(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+// string_arg stores the value of argument position of call in s, if it is a
+// constant string.
+func string_arg(call *ast.CallExpr, position int, s *string) bool {
+	if position > len(call.Args) {
+		return false
+	}
+	value, ok := constant_string(call.Args[position-1])
+	if ok {
+		*s = value
+	}
+	return ok
+}
+
+// constant_string evaluates string literals and concatenations of them.
+func constant_string(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(expr.Value)
+		return s, err == nil
+	case *ast.ParenExpr:
+		return constant_string(expr.X)
+	case *ast.BinaryExpr:
+		if expr.Op != token.ADD {
+			return "", false
+		}
+		left, ok := constant_string(expr.X)
+		if !ok {
+			return "", false
+		}
+		right, ok := constant_string(expr.Y)
+		return left + right, ok
+	}
+	return "", false
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
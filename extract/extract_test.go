@@ -0,0 +1,170 @@
+package extract
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ojii/gettext.go"
+)
+
+const source = `package main
+
+import "fmt"
+
+func T(msgid string) string {
+	return msgid
+}
+
+func main(catalog gettext.Catalog, domains gettext.Domains, n uint32) {
+	// TRANSLATORS: shown when the app starts
+	fmt.Println(catalog.Gettext("Hello"))
+	// Not for translators
+	fmt.Println(catalog.Gettext("Goodbye"))
+	fmt.Printf(catalog.NGettext("%d apple", "%d apples", n), n)
+	fmt.Println(catalog.PGettext("verb", "Open"))
+	fmt.Println(catalog.NPGettext("mail", "%d message", "%d messages", n))
+	fmt.Println(catalog.Gettext("Hello"))
+	fmt.Println(catalog.Gettext("con" + "cat" + ` + "`enated`" + `))
+	fmt.Println(T("wrapped"))
+	variable := "dynamic"
+	fmt.Println(catalog.Gettext(variable))
+	fmt.Println(catalog.Gettext("line\nbreak"))
+	fmt.Println(domains.DPGettext("errors", "http", "Open"))
+}
+`
+
+func TestParseKeyword(t *testing.T) {
+	for spec, expected := range map[string]Keyword{
+		"T":                {Name: "T", ID: 1},
+		"T:2":              {Name: "T", ID: 2},
+		"T:1,2":            {Name: "T", ID: 1, Plural: 2},
+		"T:1c,2":           {Name: "T", ID: 2, Context: 1},
+		"T:2,3,1c":         {Name: "T", ID: 2, Plural: 3, Context: 1},
+		"NPGettext:1c,2,3": {Name: "NPGettext", ID: 2, Plural: 3, Context: 1},
+	} {
+		got, err := ParseKeyword(spec)
+		if err != nil {
+			t.Errorf("%q: %s", spec, err)
+		} else if got != expected {
+			t.Errorf("%q: expected %+v, got %+v", spec, expected, got)
+		}
+	}
+	for _, spec := range []string{"", ":1", "T:", "T:x", "T:0", "T:1c", "T:1,2,3", "T:1c,2c,3"} {
+		if _, err := ParseKeyword(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	extractor := NewExtractor()
+	extractor.Keywords = append(DefaultKeywords, Keyword{Name: "T", ID: 1})
+	err := extractor.AddFile("app/main.go", []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pot := extractor.Template()
+	expected := []*gettext.POEntry{
+		{
+			ExtractedComments: []string{"TRANSLATORS: shown when the app starts"},
+			References:        []string{"app/main.go:11", "app/main.go:17"},
+			ID:                "Hello",
+			Str:               []string{""},
+		},
+		{References: []string{"app/main.go:13"}, ID: "Goodbye", Str: []string{""}},
+		{References: []string{"app/main.go:14"}, ID: "%d apple", IDPlural: "%d apples", Str: []string{"", ""}},
+		{References: []string{"app/main.go:15"}, HasContext: true, Context: "verb", ID: "Open", Str: []string{""}},
+		{References: []string{"app/main.go:16"}, HasContext: true, Context: "mail", ID: "%d message", IDPlural: "%d messages", Str: []string{"", ""}},
+		{References: []string{"app/main.go:18"}, ID: "concatenated", Str: []string{""}},
+		{References: []string{"app/main.go:19"}, ID: "wrapped", Str: []string{""}},
+		{References: []string{"app/main.go:22"}, ID: "line\nbreak", Str: []string{""}},
+		{References: []string{"app/main.go:23"}, HasContext: true, Context: "http", ID: "Open", Str: []string{""}},
+	}
+	if len(pot.Entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(pot.Entries))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(pot.Entries[i], expected[i]) {
+			t.Errorf("expected %+v, got %+v", expected[i], pot.Entries[i])
+		}
+	}
+	if !pot.Header.HasFlag("fuzzy") || pot.HeaderField("Content-Type") != "text/plain; charset=UTF-8" {
+		t.Errorf("unexpected header %+v", pot.Header)
+	}
+
+	var out bytes.Buffer
+	_, err = pot.WriteTo(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, snippet := range []string{
+		"#. TRANSLATORS: shown when the app starts\n#: app/main.go:11 app/main.go:17\nmsgid \"Hello\"\nmsgstr \"\"\n",
+		"msgctxt \"mail\"\nmsgid \"%d message\"\nmsgid_plural \"%d messages\"\nmsgstr[0] \"\"\nmsgstr[1] \"\"\n",
+	} {
+		if !strings.Contains(out.String(), snippet) {
+			t.Errorf("expected %q in template", snippet)
+		}
+	}
+	// The template has to be readable by the po parser
+	if _, err := gettext.ParsePO(&out); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExtractWithoutComments(t *testing.T) {
+	extractor := NewExtractor()
+	extractor.CommentTag = ""
+	err := extractor.AddFile("main.go", []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range extractor.Template().Entries {
+		if len(entry.ExtractedComments) != 0 {
+			t.Errorf("unexpected comments %q", entry.ExtractedComments)
+		}
+		if entry.ID == "wrapped" {
+			t.Error("T is not a default keyword")
+		}
+	}
+}
+
+func TestExtractZeroValue(t *testing.T) {
+	var extractor Extractor
+	if len(extractor.Template().Entries) != 0 {
+		t.Error("expected an empty template")
+	}
+	err := extractor.AddFile("main.go", []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := extractor.Template().Entries
+	if len(entries) == 0 || entries[0].ID != "Hello" || len(entries[0].ExtractedComments) != 0 {
+		t.Errorf("unexpected entries %v", entries)
+	}
+}
+
+func TestExtractSyntaxError(t *testing.T) {
+	err := NewExtractor().AddFile("broken.go", []byte("package main\nfunc {"))
+	if err == nil {
+		t.Error("expected a syntax error")
+	}
+}
+
+func TestExtractLookup(t *testing.T) {
+	extractor := NewExtractor()
+	err := extractor.AddFile("main.go", []byte(`package main
+
+func main() {
+	msgstr, ok := catalog.NPLookup("menu", "%d item", "%d items", 2)
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := extractor.Template().Entries
+	if len(entries) != 1 || entries[0].Context != "menu" || entries[0].IDPlural != "%d items" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
@@ -0,0 +1,112 @@
+"""Generates charset_tables.go from the codecs of Python's standard library.
+
+Usage: python3 gencharsets.py > charset_tables.go
+"""
+import codecs
+
+
+SINGLE_BYTE = [
+    ("iso88591", "iso8859_1"),
+    ("iso88592", "iso8859_2"),
+    ("iso88593", "iso8859_3"),
+    ("iso88594", "iso8859_4"),
+    ("iso88595", "iso8859_5"),
+    ("iso88596", "iso8859_6"),
+    ("iso88597", "iso8859_7"),
+    ("iso88598", "iso8859_8"),
+    ("iso88599", "iso8859_9"),
+    ("iso885910", "iso8859_10"),
+    ("iso885911", "iso8859_11"),
+    ("iso885913", "iso8859_13"),
+    ("iso885914", "iso8859_14"),
+    ("iso885915", "iso8859_15"),
+    ("iso885916", "iso8859_16"),
+    ("koi8r", "koi8_r"),
+    ("koi8u", "koi8_u"),
+    ("windows1250", "cp1250"),
+    ("windows1251", "cp1251"),
+    ("windows1252", "cp1252"),
+    ("windows1253", "cp1253"),
+    ("windows1254", "cp1254"),
+    ("windows1255", "cp1255"),
+    ("windows1256", "cp1256"),
+    ("windows1257", "cp1257"),
+    ("windows1258", "cp1258"),
+]
+
+
+def decode(data, codec):
+    try:
+        text = data.decode(codec)
+    except UnicodeDecodeError:
+        return 0
+    if len(text) != 1:
+        return 0
+    return ord(text)
+
+
+def single_byte(codec):
+    return [decode(bytes([b]), codec) for b in range(0x80, 0x100)]
+
+
+def jis(prefix):
+    """Decodes the 94x94 JIS X 0208 or 0212 characters from their EUC-JP form."""
+    return [
+        decode(prefix + bytes([0xA1 + row, 0xA1 + cell]), "euc_jp")
+        for row in range(94)
+        for cell in range(94)
+    ]
+
+
+def check_shift_jis(jisx0208):
+    """Makes sure Shift_JIS maps to the same JIS X 0208 table as EUC-JP."""
+    for index, expected in enumerate(jisx0208):
+        row, cell = divmod(index, 94)
+        s1 = (row >> 1) + (0x81 if row < 62 else 0xC1)
+        if row % 2 == 0:
+            s2 = cell + (0x40 if cell < 63 else 0x41)
+        else:
+            s2 = cell + 0x9F
+        got = decode(bytes([s1, s2]), "shift_jis")
+        assert got == expected or expected == 0, (hex(s1), hex(s2))
+
+
+def table(values, width):
+    lines = []
+    for i in range(0, len(values), width):
+        lines.append("\t" + " ".join("0x%04x," % v for v in values[i:i + width]))
+    return "\n".join(lines)
+
+
+def gen():
+    out = [
+        "// Code generated by gencharsets.py; DO NOT EDIT.",
+        "",
+        "package gettext",
+        "",
+        "// single_byte_charsets map the bytes 0x80 to 0xff of ASCII compatible",
+        "// single byte charsets to runes, 0 marks undefined bytes.",
+        "var single_byte_charsets = map[string]*[128]rune{",
+    ]
+    for name, codec in SINGLE_BYTE:
+        codecs.lookup(codec)
+        out.append('\t"%s": {' % name)
+        out.append("\t" + table(single_byte(codec), 8).replace("\n", "\n\t"))
+        out.append("\t},")
+    out.append("}")
+    jisx0208 = jis(b"")
+    check_shift_jis(jisx0208)
+    for name, values in [("jisx0208", jisx0208), ("jisx0212", jis(b"\x8f"))]:
+        assert max(values) <= 0xFFFF
+        out.append("")
+        out.append("// %s maps the 94x94 characters of %s, row by row, to runes, 0"
+                   % (name, name.upper().replace("JISX", "JIS X ")))
+        out.append("// marks undefined characters.")
+        out.append("var %s = [94 * 94]uint16{" % name)
+        out.append(table(values, 8))
+        out.append("}")
+    return "\n".join(out) + "\n"
+
+
+if __name__ == "__main__":
+    print(gen(), end="")
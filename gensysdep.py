@@ -0,0 +1,152 @@
+"""Generates sysdep/messages.mo, a revision 1 mo file with system dependent
+strings, laid out like GNU msgfmt does for c-format strings using
+<inttypes.h> macros.
+
+Usage: python3 gensysdep.py > testdata/sysdep/messages.mo
+
+Segments are written as <NAME> in the strings below. They stand for "%<NAME>"
+in po files, except for <I>, which is the "I" flag of "%Id".
+"""
+import re
+import struct
+import sys
+
+HEADER = (
+    "Language: de\n"
+    "MIME-Version: 1.0\n"
+    "Content-Type: text/plain; charset=UTF-8\n"
+    "Content-Transfer-Encoding: 8bit\n"
+    "Plural-Forms: nplurals=2; plural=(n != 1);\n"
+)
+
+STATIC = [
+    ("", HEADER),
+    ("greeting", "Hallo"),
+]
+
+SYSDEP = [
+    ("%<PRIu64> file\0%<PRIu64> files", "%<PRIu64> Datei\0%<PRIu64> Dateien"),
+    ("progress\x04%<PRId32>%% done", "%<PRId32>%% fertig"),
+    ("%<I>d apples", "%<I>d Äpfel"),
+    ("address %#<PRIxPTR>", "Adresse %#<PRIxPTR>"),
+    ("%<PRIdMAX> of %<PRIdLEAST16>", "%<PRIdMAX> von %<PRIdLEAST16>"),
+    # Not a known macro, left out by the reader
+    ("%<PRIq64> unknown", "%<PRIq64> unbekannt"),
+]
+
+SEGMENT = re.compile(r"<(\w+)>")
+
+
+def hash_string(s):
+    hv = 0
+    for c in s:
+        hv = (hv << 4) + c
+        g = hv & 0xF0000000
+        if g:
+            hv ^= g >> 24
+            hv ^= g
+    return hv
+
+
+def next_prime(n):
+    while True:
+        if n > 1 and all(n % d for d in range(2, int(n ** 0.5) + 1)):
+            return n
+        n += 1
+
+
+def split(s):
+    """Splits a string into static pieces and the segments between them."""
+    parts = SEGMENT.split(s)
+    return [p.encode() for p in parts[0::2]], parts[1::2]
+
+
+def gen():
+    static = sorted((k.encode(), v.encode()) for k, v in STATIC)
+    n = len(static)
+    hash_size = next_prime((n + len(SYSDEP)) * 4 // 3)
+
+    segments = []
+    for pair in SYSDEP:
+        for s in pair:
+            for name in split(s)[1]:
+                if name not in segments:
+                    segments.append(name)
+
+    header_size = 48
+    orig_tab = header_size
+    trans_tab = orig_tab + 8 * n
+    hash_tab = trans_tab + 8 * n
+    segments_tab = hash_tab + 4 * hash_size
+    orig_sysdep_tab = segments_tab + 8 * len(segments)
+    trans_sysdep_tab = orig_sysdep_tab + 4 * len(SYSDEP)
+    descriptions_start = trans_sysdep_tab + 4 * len(SYSDEP)
+
+    # Each description is the offset of the static data followed by
+    # (segsize, sysdepref) pairs, the last one ending with 0xffffffff.
+    sysdep_strings = [k for k, _ in SYSDEP] + [v for _, v in SYSDEP]
+    descriptions_size = sum(4 + 8 * (len(split(s)[1]) + 1) for s in sysdep_strings)
+    strings_start = descriptions_start + descriptions_size
+
+    data = b""
+
+    def add(s):
+        nonlocal data
+        offset = strings_start + len(data)
+        data += s
+        return offset
+
+    orig = [(len(k), add(k + b"\0")) for k, _ in static]
+    trans = [(len(v), add(v + b"\0")) for _, v in static]
+    segment_entries = [(len(name), add(name.encode() + b"\0")) for name in segments]
+
+    descriptions = b""
+    description_offsets = []
+    for s in sysdep_strings:
+        description_offsets.append(descriptions_start + len(descriptions))
+        pieces, names = split(s)
+        pieces[-1] += b"\0"
+        descriptions += struct.pack("<I", add(b"".join(pieces)))
+        for piece, name in zip(pieces, names + [None]):
+            ref = 0xFFFFFFFF if name is None else segments.index(name)
+            descriptions += struct.pack("<2I", len(piece), ref)
+    assert len(descriptions) == descriptions_size
+
+    table = [0] * hash_size
+    for i, (k, _) in enumerate(static):
+        hv = hash_string(k.split(b"\0")[0])
+        index = hv % hash_size
+        incr = 1 + hv % (hash_size - 2)
+        while table[index]:
+            index = index - (hash_size - incr) if index >= hash_size - incr else index + incr
+        table[index] = i + 1
+
+    out = struct.pack(
+        "<12I",
+        0x950412DE,
+        1 << 16,
+        n,
+        orig_tab,
+        trans_tab,
+        hash_size,
+        hash_tab,
+        len(segments),
+        segments_tab,
+        len(SYSDEP),
+        orig_sysdep_tab,
+        trans_sysdep_tab,
+    )
+    for length, offset in orig + trans:
+        out += struct.pack("<2I", length, offset)
+    out += struct.pack("<%dI" % hash_size, *table)
+    for length, offset in segment_entries:
+        out += struct.pack("<2I", length, offset)
+    out += struct.pack("<%dI" % len(SYSDEP), *description_offsets[: len(SYSDEP)])
+    out += struct.pack("<%dI" % len(SYSDEP), *description_offsets[len(SYSDEP):])
+    out += descriptions
+    assert len(out) == strings_start
+    return out + data
+
+
+if __name__ == "__main__":
+    sys.stdout.buffer.write(gen())
@@ -3,18 +3,132 @@
 package gettext
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path"
+	"sync"
+	"sync/atomic"
 )
 
 // Translations holds the translations in the different locales your app
-// supports. Use NewTranslations to create an instance.
+// supports. Use NewTranslations to create an instance. Translations is safe
+// for concurrent use, copies of it share the same catalogs.
 type Translations struct {
-	cache    map[string]Catalog
-	root     string
-	domain   string
-	resolver PathResolver
+	cache          *cache
+	fsys           fs.FS
+	root           string
+	domain         string
+	resolver       PathResolver
+	fallback       FallbackFunc
+	default_locale string
+	missing        MissingFunc
+	lazy           bool
+}
+
+// Option configures optional behaviour of Translations.
+type Option func(t *Translations)
+
+// WithFallback sets how requested locales map to the locales translations are
+// looked up in. The default is DefaultFallback, use NoFallback to only ever
+// look at the exact locale requested.
+func WithFallback(fallback FallbackFunc) Option {
+	return func(t *Translations) {
+		t.fallback = fallback
+	}
+}
+
+// WithDefaultLocale sets a locale to fall back to after all locales returned
+// by the FallbackFunc, typically the language the msgids are not written in.
+func WithDefaultLocale(locale string) Option {
+	return func(t *Translations) {
+		t.default_locale = locale
+	}
+}
+
+// WithLazyCatalogs makes Translations load catalogs like ParseMOLazy, or
+// OpenMO for mo files on the operating system's file system. Memory mapped mo
+// files are only checked for changes by their size and modification time,
+// and have to be replaced rather than overwritten in place.
+func WithLazyCatalogs() Option {
+	return func(t *Translations) {
+		t.lazy = true
+	}
+}
+
+// cache holds the catalogs loaded so far, keyed by locale and domain. Each
+// catalog is loaded at most once until it is reloaded, no matter how many
+// goroutines ask for it at once.
+type cache struct {
+	mu      sync.Mutex
+	entries map[cache_key]*cache_entry
+}
+
+type cache_key struct {
+	locale string
+	domain string
+}
+
+// cache_entry holds the catalog of one locale and domain. The state is
+// swapped as a whole when the catalog is reloaded, so lookups never see a
+// partially loaded catalog.
+type cache_entry struct {
+	once  sync.Once
+	mu    sync.Mutex
+	state atomic.Pointer[catalog_state]
+}
+
+func new_cache() *cache {
+	return &cache{entries: map[cache_key]*cache_entry{}}
+}
+
+func (c *cache) entry(locale string, domain string) *cache_entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cache_key{locale: locale, domain: domain}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cache_entry{}
+		c.entries[key] = entry
+	}
+	return entry
+}
+
+// all returns a copy of the entries loaded so far.
+func (c *cache) all() map[cache_key]*cache_entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make(map[cache_key]*cache_entry, len(c.entries))
+	for key, entry := range c.entries {
+		entries[key] = entry
+	}
+	return entries
+}
+
+// ErrNotFound is wrapped by the errors returned for locales that have no mo
+// file.
+var ErrNotFound = errors.New("catalog not found")
+
+// LoadError describes why the catalog for a locale and domain could not be
+// loaded. Use errors.Is with ErrNotFound, ErrCorruptCatalog, ErrPluralForms or
+// ErrUnknownCharset to find out what went wrong. Catalogs failing with
+// ErrPluralFormCount are used nonetheless.
+type LoadError struct {
+	Locale string
+	Domain string
+	Path   string
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("gettext: loading locale %q from %s: %v", e.Locale, e.Path, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
 }
 
 // PathResolver resolves a path to a mo file
@@ -26,52 +140,286 @@ func DefaultResolver(root string, locale string, domain string) string {
 	return path.Join(root, locale, "LC_MESSAGES", fmt.Sprintf("%s.mo", domain))
 }
 
+// osfs opens files from the operating system's file system. Unlike os.DirFS
+// it accepts any path a PathResolver comes up with, including absolute ones.
+type osfs struct{}
+
+func (osfs) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
 // NewTranslations is the main entry point for gogettext. Use this to set up
 // the locales for your app.
-// root is the root of your locale folder, domain the domain you want to load
-// and resolver a function that resolves mo file paths.
+// root is the root of your locale folder, domain the default domain and
+// resolver a function that resolves mo file paths. Other domains are loaded
+// on demand, see Domain and Domains.
 // If your structure is <root>/<locale>/LC_MESSAGES/<domain>.mo, you can use
 // DefaultResolver.
-func NewTranslations(root string, domain string, resolver PathResolver) Translations {
-	return Translations{
+func NewTranslations(root string, domain string, resolver PathResolver, options ...Option) Translations {
+	return new_translations(osfs{}, root, domain, resolver, options)
+}
+
+// NewTranslationsFS is like NewTranslations, but reads mo files from fsys, for
+// example an embed.FS. The resolver is called with a root of ".", so it has to
+// return paths that are valid for fs.FS, which DefaultResolver does.
+func NewTranslationsFS(fsys fs.FS, domain string, resolver PathResolver, options ...Option) Translations {
+	return new_translations(fsys, ".", domain, resolver, options)
+}
+
+func new_translations(fsys fs.FS, root string, domain string, resolver PathResolver, options []Option) Translations {
+	t := Translations{
+		fsys:     fsys,
 		root:     root,
 		resolver: resolver,
 		domain:   domain,
-		cache:    map[string]Catalog{},
+		fallback: DefaultFallback,
+		cache:    new_cache(),
 	}
+	for _, option := range options {
+		option(&t)
+	}
+	return t
 }
 
 // Preload a list of locales (if they're available). This is useful if you want
 // to limit IO to a specific time in your app, for example startup. Subsequent
 // calls to Preload or Locale using a locale given here will not do any IO.
-func (t Translations) Preload(locales ...string) {
+// Locales without a mo file are skipped, the returned error joins the errors
+// of all other locales that failed to load.
+func (t Translations) Preload(locales ...string) error {
+	return t.PreloadDomain(t.domain, locales...)
+}
+
+// PreloadDomain is like Preload, but for the given domain instead of the
+// default one.
+func (t Translations) PreloadDomain(domain string, locales ...string) error {
+	var errs []error
 	for _, locale := range locales {
-		t.load(locale)
+		for _, candidate := range t.candidates(locale) {
+			_, err := t.get(candidate, domain)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				errs = append(errs, err)
+			}
+		}
 	}
+	return errors.Join(errs...)
 }
 
-func (t Translations) load(locale string) {
-	path := t.resolver(t.root, locale, t.domain)
-	f, err := os.Open(path)
+// candidates returns the locales to look up translations for locale in.
+func (t Translations) candidates(locale string) []string {
+	candidates := t.fallback(locale)
+	if t.default_locale != "" && !contains(candidates, t.default_locale) {
+		candidates = append(candidates, t.default_locale)
+	}
+	return candidates
+}
+
+// load reads and parses the mo file of locale and domain. If its content is
+// the same as when old was loaded, old's catalog is reused without parsing.
+// old may be nil.
+func (t Translations) load(locale string, domain string, old *catalog_state) *catalog_state {
+	path := t.resolver(t.root, locale, domain)
+	state := &catalog_state{catalog: nullcatalog{}}
+	f, err := t.fsys.Open(path)
 	if err != nil {
-		t.cache[locale] = nullcatalog{}
-		return
+		if errors.Is(err, fs.ErrNotExist) {
+			err = fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+		state.err = &LoadError{Locale: locale, Domain: domain, Path: path, Err: err}
+		return state
 	}
 	defer f.Close()
-	catalog, err := ParseMO(f)
+	if info, err := f.Stat(); err == nil {
+		state.size, state.modtime = info.Size(), info.ModTime()
+	}
+	var catalog Catalog
+	if file, ok := f.(*os.File); ok && t.lazy {
+		catalog, err = parse_mo_file(file)
+	} else {
+		var data []byte
+		data, err = io.ReadAll(f)
+		if err != nil {
+			state.err = &LoadError{Locale: locale, Domain: domain, Path: path, Err: err}
+			return state
+		}
+		state.hash = sha256.Sum256(data)
+		state.hashed = true
+		if old != nil && old.hashed && usable(old.err) && old.hash == state.hash {
+			state.catalog, state.err = old.catalog, old.err
+			return state
+		}
+		if t.lazy {
+			catalog, err = ParseMOLazy(data)
+		} else {
+			catalog, err = ParseMOBytes(data)
+		}
+	}
 	if err != nil {
-		t.cache[locale] = nullcatalog{}
-		return
+		state.err = &LoadError{Locale: locale, Domain: domain, Path: path, Err: err}
+		if !usable(err) {
+			return state
+		}
 	}
-	t.cache[locale] = catalog
+	state.catalog = catalog
+	return state
 }
 
-// Locale returns the catalog translations for a given Locale. If the given
-// locale is not available, a NullCatalog is returned.
+// usable tells whether a catalog loaded with err can be used.
+func usable(err error) bool {
+	return err == nil || errors.Is(err, ErrPluralFormCount)
+}
+
+func (t Translations) get(locale string, domain string) (Catalog, error) {
+	state := t.current(t.cache.entry(locale, domain), cache_key{locale: locale, domain: domain})
+	return state.catalog, state.err
+}
+
+// current returns the state of entry, loading it the first time.
+func (t Translations) current(entry *cache_entry, key cache_key) *catalog_state {
+	entry.once.Do(func() {
+		entry.state.Store(t.load(key.locale, key.domain, nil))
+	})
+	return entry.state.Load()
+}
+
+// Locale returns the catalog translations of the default domain for a given
+// Locale. Messages not translated in the locale itself are looked up in its
+// fallback locales. If neither the locale nor its fallbacks are available, a
+// NullCatalog is returned.
 func (t Translations) Locale(locale string) Catalog {
-	_, ok := t.cache[locale]
-	if !ok {
-		t.load(locale)
+	catalog, _ := t.LocaleE(locale)
+	return catalog
+}
+
+// LocaleE is like Locale, but also returns why the locale is not available.
+// If at least one of the fallback locales could be loaded, only errors other
+// than ErrNotFound are reported. The returned Catalog can be used even if the
+// error is not nil.
+func (t Translations) LocaleE(locale string) (Catalog, error) {
+	return t.DomainE(locale, t.domain)
+}
+
+// Domain is like Locale, but returns the catalog of the given domain instead
+// of the default one.
+func (t Translations) Domain(locale string, domain string) Catalog {
+	catalog, _ := t.DomainE(locale, domain)
+	return catalog
+}
+
+// DomainE is like LocaleE, but for the given domain instead of the default
+// one.
+func (t Translations) DomainE(locale string, domain string) (Catalog, error) {
+	return t.domain_catalog(locale, t.candidates(locale), domain)
+}
+
+// domain_catalog is DomainE with the candidates of locale already resolved.
+func (t Translations) domain_catalog(locale string, candidates []string, domain string) (Catalog, error) {
+	catalog, err := t.lookup(candidates, domain)
+	if t.missing != nil {
+		catalog = ReportMissing(catalog, locale, domain, t.missing)
+	}
+	return catalog, err
+}
+
+func (t Translations) lookup(candidates []string, domain string) (Catalog, error) {
+	var found []Catalog
+	var errs []error
+	for _, candidate := range candidates {
+		catalog, err := t.get(candidate, domain)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if usable(err) {
+			found = append(found, catalog)
+		}
+	}
+	if len(found) == 0 {
+		return nullcatalog{}, errors.Join(errs...)
 	}
-	return t.cache[locale]
+	var reported []error
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotFound) {
+			reported = append(reported, err)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], errors.Join(reported...)
+	}
+	return fallbackcatalog(found), errors.Join(reported...)
+}
+
+// Domains gives access to all domains of a locale. The Catalog methods look
+// messages up in the default domain, the D variants in the given one.
+type Domains struct {
+	Catalog
+	translations Translations
+	locale       string
+	candidates   []string
+	catalogs     *domain_catalogs
+}
+
+// domain_catalogs holds the catalogs of the domains a Domains looked messages
+// up in so far, shared by its copies.
+type domain_catalogs struct {
+	mu       sync.Mutex
+	catalogs map[string]Catalog
+}
+
+// Domains returns the catalogs of all domains for locale. Domains are loaded
+// the first time a message is looked up in them, and then kept like the
+// catalogs returned by Locale are, call Domains again after reloading.
+func (t Translations) Domains(locale string) Domains {
+	candidates := t.candidates(locale)
+	catalog, _ := t.domain_catalog(locale, candidates, t.domain)
+	return Domains{
+		Catalog:      catalog,
+		translations: t,
+		locale:       locale,
+		candidates:   candidates,
+		catalogs:     &domain_catalogs{catalogs: map[string]Catalog{t.domain: catalog}},
+	}
+}
+
+// Domain returns the catalog of the given domain.
+func (d Domains) Domain(domain string) Catalog {
+	d.catalogs.mu.Lock()
+	catalog, ok := d.catalogs.catalogs[domain]
+	d.catalogs.mu.Unlock()
+	if ok {
+		return catalog
+	}
+	// Loaded without holding the lock, so other domains can be looked up
+	// meanwhile. The cache loads each catalog once anyway.
+	catalog, _ = d.translations.domain_catalog(d.locale, d.candidates, domain)
+	d.catalogs.mu.Lock()
+	defer d.catalogs.mu.Unlock()
+	if existing, ok := d.catalogs.catalogs[domain]; ok {
+		return existing
+	}
+	d.catalogs.catalogs[domain] = catalog
+	return catalog
+}
+
+// DGettext is Gettext in domain. If the domain is not found, msgid is
+// returned untranslated.
+func (d Domains) DGettext(domain string, msgid string) string {
+	return d.Domain(domain).Gettext(msgid)
+}
+
+// DNGettext is NGettext in domain. If the domain is not found, msgid or
+// msgid_plural is returned untranslated, depending on n.
+func (d Domains) DNGettext(domain string, msgid string, msgid_plural string, n uint32) string {
+	return d.Domain(domain).NGettext(msgid, msgid_plural, n)
+}
+
+// DPGettext is PGettext in domain. If the domain is not found, msgid is
+// returned untranslated.
+func (d Domains) DPGettext(domain string, context string, msgid string) string {
+	return d.Domain(domain).PGettext(context, msgid)
+}
+
+// DNPGettext is NPGettext in domain. If the domain is not found, msgid or
+// msgid_plural is returned untranslated, depending on n.
+func (d Domains) DNPGettext(domain string, context string, msgid string, msgid_plural string, n uint32) string {
+	return d.Domain(domain).NPGettext(context, msgid, msgid_plural, n)
 }
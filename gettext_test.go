@@ -6,6 +6,14 @@ import (
 	"testing"
 	"io/ioutil"
 	"os"
+
+	"bytes"
+	"embed"
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"testing/fstest"
 )
 
 func TestNullTranslations(t *testing.T) {
@@ -28,6 +36,9 @@ func TestNullTranslations(t *testing.T) {
 	assert_equal(t, ja_ngettext_1, "mymsgid")
 	ja_ngettext_2 := ja.NGettext("mymsgid", "mymsgidp", 2)
 	assert_equal(t, ja_ngettext_2, "mymsgidp")
+	assert_equal(t, ja.PGettext("myctxt", "mymsgid"), "mymsgid")
+	assert_equal(t, ja.NPGettext("myctxt", "mymsgid", "mymsgidp", 1), "mymsgid")
+	assert_equal(t, ja.NPGettext("myctxt", "mymsgid", "mymsgidp", 2), "mymsgidp")
 }
 
 func my_resolver(root string, locale string, domain string) string {
@@ -201,4 +212,296 @@ func TestNotMoFile(t *testing.T) {
 		"order 2 beers",
 	)
 
-}
\ No newline at end of file
+}
+
+//go:embed testdata
+var embedded embed.FS
+
+func TestTranslationsFS(t *testing.T) {
+	en_mo, err := os.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapfs := fstest.MapFS{
+		"en/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: en_mo},
+	}
+	translations := NewTranslationsFS(mapfs, "messages", DefaultResolver)
+	en := translations.Locale("en")
+	assert_equal(t, en.Gettext("greeting"), "Hello")
+	assert_equal(t,
+		fmt.Sprintf(en.NGettext("order %d beer", "order %d beers", 2), 2),
+		"2 beers please",
+	)
+	ja := translations.Locale("ja")
+	assert_equal(t, ja.Gettext("greeting"), "greeting")
+}
+
+func TestTranslationsEmbedFS(t *testing.T) {
+	fsys, err := fs.Sub(embedded, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	translations := NewTranslationsFS(fsys, "messages", my_resolver)
+	assert_equal(t, translations.Locale("en").Gettext("greeting"), "Hello")
+	assert_equal(t, translations.Locale("ja").Gettext("greeting"), "こんいちは")
+	assert_equal(t, translations.Locale("de").Gettext("greeting"), "greeting")
+}
+
+func TestTranslationsDirFS(t *testing.T) {
+	translations := NewTranslationsFS(os.DirFS("testdata"), "messages", my_resolver)
+	assert_equal(t, translations.Locale("ja").Gettext("greeting"), "こんいちは")
+}
+
+func TestConcurrentLocale(t *testing.T) {
+	var resolved int32
+	resolver := func(root string, locale string, domain string) string {
+		atomic.AddInt32(&resolved, 1)
+		return my_resolver(root, locale, domain)
+	}
+	translations := NewTranslations("testdata/", "messages", resolver)
+	locales := []string{"en", "ja", "fr", "de"}
+	expected := map[string]string{
+		"en": "Hello",
+		"ja": "こんいちは",
+		"fr": "Bonjour",
+		"de": "greeting",
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%8 == 0 {
+				translations.Preload(locales...)
+			}
+			for j := range locales {
+				locale := locales[(i+j)%len(locales)]
+				got := translations.Locale(locale).Gettext("greeting")
+				if got != expected[locale] {
+					t.Errorf("%s: expected %q, got %q", locale, expected[locale], got)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if resolved != int32(len(locales)) {
+		t.Errorf("expected %d loads, got %d", len(locales), resolved)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	en_mo, err := os.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad_plural := bytes.Replace(en_mo, []byte("(n != 1)"), []byte("(n ^^ 1)"), 1)
+	mapfs := fstest.MapFS{
+		"en/LC_MESSAGES/messages.mo":      &fstest.MapFile{Data: en_mo},
+		"corrupt/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: en_mo[:100]},
+		"plural/LC_MESSAGES/messages.mo":  &fstest.MapFile{Data: bad_plural},
+	}
+	translations := NewTranslationsFS(mapfs, "messages", DefaultResolver)
+
+	en, err := translations.LocaleE("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, en.Gettext("greeting"), "Hello")
+
+	missing, err := translations.LocaleE("de")
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	assert_equal(t, missing.Gettext("greeting"), "greeting")
+
+	corrupt, err := translations.LocaleE("corrupt")
+	if !errors.Is(err, ErrCorruptCatalog) || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected corrupt catalog error, got %v", err)
+	}
+	assert_equal(t, corrupt.Gettext("greeting"), "greeting")
+
+	_, err = translations.LocaleE("plural")
+	if !errors.Is(err, ErrPluralForms) || errors.Is(err, ErrCorruptCatalog) {
+		t.Errorf("expected plural forms error, got %v", err)
+	}
+	var load_error *LoadError
+	if !errors.As(err, &load_error) {
+		t.Fatalf("expected a LoadError, got %T", err)
+	}
+	assert_equal(t, load_error.Locale, "plural")
+	assert_equal(t, load_error.Path, "plural/LC_MESSAGES/messages.mo")
+}
+
+func TestLoadPluralFormCount(t *testing.T) {
+	// Catalogs with plural messages missing forms are reported, but used
+	forms := encode_mo([]mo_message{
+		{key: "", str: "Plural-Forms: nplurals=3; plural=n == 1 ? 0 : n == 2 ? 1 : 2;\n"},
+		{key: "%d file\x00%d files", str: "%d Datei\x00%d Dateien"},
+		{key: "greeting", str: "Hallo"},
+	}, MOOptions{})
+	mapfs := fstest.MapFS{
+		"de/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: forms},
+	}
+	for _, translations := range []Translations{
+		NewTranslationsFS(mapfs, "messages", DefaultResolver),
+		NewTranslationsFS(mapfs, "messages", DefaultResolver, WithLazyCatalogs()),
+	} {
+		de, err := translations.LocaleE("de_AT")
+		if !errors.Is(err, ErrPluralFormCount) {
+			t.Errorf("expected a plural form count error, got %v", err)
+		}
+		assert_equal(t, de.Gettext("greeting"), "Hallo")
+		assert_equal(t, de.NGettext("%d file", "%d files", 2), "%d Dateien")
+		assert_equal(t, de.NGettext("%d file", "%d files", 3), "%d files")
+		if err := translations.ReloadAll(); !errors.Is(err, ErrPluralFormCount) {
+			t.Errorf("expected a plural form count error, got %v", err)
+		}
+		assert_equal(t, translations.Locale("de").Gettext("greeting"), "Hallo")
+	}
+}
+
+func TestPreloadErrors(t *testing.T) {
+	en_mo, err := os.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapfs := fstest.MapFS{
+		"en/LC_MESSAGES/messages.mo":      &fstest.MapFile{Data: en_mo},
+		"corrupt/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: []byte("not a mo file")},
+	}
+	translations := NewTranslationsFS(mapfs, "messages", DefaultResolver)
+	err = translations.Preload("en", "de")
+	if err != nil {
+		t.Errorf("missing locales should be skipped, got %v", err)
+	}
+	err = translations.Preload("en", "de", "corrupt")
+	if !errors.Is(err, ErrCorruptCatalog) {
+		t.Errorf("expected corrupt catalog error, got %v", err)
+	}
+}
+
+func TestFallbackLocales(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver)
+	for _, locale := range []string{"en_GB", "en-GB", "en-gb", "en_GB.UTF-8"} {
+		en_gb := translations.Locale(locale)
+		assert_equal(t, en_gb.Gettext("greeting"), "Good day")
+		// not translated in en_GB, so it falls back to en
+		assert_equal(t,
+			fmt.Sprintf(en_gb.NGettext("order %d beer", "order %d beers", 2), 2),
+			"2 beers please",
+		)
+		assert_equal(t, en_gb.Gettext("unknown"), "unknown")
+		assert_equal(t, en_gb.NGettext("unknown", "unknowns", 2), "unknowns")
+	}
+	assert_equal(t, translations.Locale("ja_JP").Gettext("greeting"), "こんいちは")
+	assert_equal(t, translations.Locale("fr-CA").PGettext("verb", "Open"), "Ouvrir")
+	assert_equal(t, translations.Locale("de_AT").Gettext("greeting"), "greeting")
+	_, err := translations.LocaleE("de_AT")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	_, err = translations.LocaleE("ja_JP")
+	if err != nil {
+		t.Errorf("expected ja_JP to fall back to ja, got %v", err)
+	}
+}
+
+func TestNoFallback(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver, WithFallback(NoFallback))
+	assert_equal(t, translations.Locale("en_GB").Gettext("greeting"), "Good day")
+	assert_equal(t,
+		translations.Locale("en_GB").NGettext("order %d beer", "order %d beers", 2),
+		"order %d beers",
+	)
+	assert_equal(t, translations.Locale("ja_JP").Gettext("greeting"), "greeting")
+}
+
+func TestDefaultLocale(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver, WithDefaultLocale("en"))
+	assert_equal(t, translations.Locale("de").Gettext("greeting"), "Hello")
+	assert_equal(t, translations.Locale("ja").Gettext("greeting"), "こんいちは")
+	assert_equal(t,
+		fmt.Sprintf(translations.Locale("fr").NGettext("order %d beer", "order %d beers", 1), 1),
+		"1 beer please",
+	)
+}
+
+func TestFallbackMetadata(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver)
+	en_gb := translations.Locale("en-GB")
+	assert_equal(t, en_gb.Language(), "en_GB")
+	assert_equal(t, en_gb.Charset(), "UTF-8")
+	assert_equal(t, translations.Locale("ja").PluralForms().Plural, "0")
+	if translations.Locale("ja").PluralForms().NPlurals != 1 {
+		t.Error("expected ja to have one plural form")
+	}
+}
+
+func TestFallbackLookup(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver)
+	en_gb := translations.Locale("en_GB")
+	msgstr, ok := en_gb.Lookup("greeting")
+	assert_lookup(t, msgstr, ok, "Good day", true)
+	msgstr, ok = en_gb.NLookup("order %d beer", "order %d beers", 1)
+	assert_lookup(t, msgstr, ok, "%d beer please", true)
+	msgstr, ok = en_gb.Lookup("missing")
+	assert_lookup(t, msgstr, ok, "missing", false)
+	msgstr, ok = en_gb.NPLookup("ctx", "one", "many", 2)
+	assert_lookup(t, msgstr, ok, "many", false)
+}
+
+func TestDomains(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver)
+	fr := translations.Domains("fr_FR")
+	assert_equal(t, fr.Gettext("greeting"), "Bonjour")
+	assert_equal(t, fr.DGettext("errors", "not found"), "introuvable")
+	assert_equal(t, fr.DGettext("errors", "greeting"), "greeting")
+	assert_equal(t, fr.DGettext("messages", "greeting"), "Bonjour")
+	assert_equal(t, fr.DNGettext("errors", "%d error", "%d errors", 2), "%d erreurs")
+	assert_equal(t, fr.DPGettext("errors", "http", "Open"), "Ouvert")
+	assert_equal(t, fr.DNPGettext("errors", "http", "%d error", "%d errors", 2), "%d errors")
+	assert_equal(t, fr.DGettext("nonexistent", "not found"), "not found")
+	assert_equal(t, translations.Domain("fr", "errors").Language(), "fr")
+}
+
+func TestDomainsMemoised(t *testing.T) {
+	// Candidates are resolved and domains looked up once per Domains
+	var calls int32
+	translations := NewTranslations("testdata/", "messages", my_resolver, WithFallback(func(locale string) []string {
+		atomic.AddInt32(&calls, 1)
+		return DefaultFallback(locale)
+	}))
+	fr := translations.Domains("fr_FR")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert_equal(t, fr.DGettext("errors", "not found"), "introuvable")
+		}()
+	}
+	wg.Wait()
+	allocs := testing.AllocsPerRun(100, func() {
+		fr.DGettext("errors", "not found")
+		fr.DGettext("messages", "greeting")
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+	if calls != 1 {
+		t.Errorf("expected one call of the FallbackFunc, got %d", calls)
+	}
+}
+
+func TestDomainErrors(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver)
+	_, err := translations.DomainE("fr", "nonexistent")
+	var load_error *LoadError
+	if !errors.As(err, &load_error) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a not found LoadError, got %v", err)
+	}
+	assert_equal(t, load_error.Domain, "nonexistent")
+	if err := translations.PreloadDomain("errors", "fr", "ja"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,108 @@
+package gettext
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LanguageRange is a single entry of an Accept-Language header.
+type LanguageRange struct {
+	Tag     string
+	Quality float64
+}
+
+// ParseAcceptLanguage parses the value of an Accept-Language header. The
+// ranges are returned in order of preference, ranges with a quality of zero
+// and malformed ones are left out.
+func ParseAcceptLanguage(header string) []LanguageRange {
+	ranges := []LanguageRange{}
+	for _, item := range strings.Split(header, ",") {
+		params := strings.Split(item, ";")
+		tag := strings.TrimSpace(params[0])
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		valid := true
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(k), "q") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			quality = q
+		}
+		if valid && quality > 0 {
+			ranges = append(ranges, LanguageRange{Tag: tag, Quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Quality > ranges[j].Quality
+	})
+	return ranges
+}
+
+// Negotiate picks the locale to use for an Accept-Language header. Language
+// ranges are tried in order of preference and match if a catalog for them or
+// one of their fallback locales is available, the wildcard "*" matches the
+// default locale. The most specific available locale is returned along with
+// its catalog. If nothing matches, the default locale is used, see
+// WithDefaultLocale.
+func (t Translations) Negotiate(accept_language string) (string, Catalog) {
+	for _, r := range ParseAcceptLanguage(accept_language) {
+		if r.Tag == "*" {
+			if t.default_locale != "" {
+				return t.default_locale, t.Locale(t.default_locale)
+			}
+			continue
+		}
+		for _, candidate := range t.fallback(r.Tag) {
+			if _, err := t.get(candidate, t.domain); err == nil {
+				return candidate, t.Locale(r.Tag)
+			}
+		}
+	}
+	if t.default_locale != "" {
+		return t.default_locale, t.Locale(t.default_locale)
+	}
+	return "", nullcatalog{}
+}
+
+// Middleware negotiates the locale of each request from its Accept-Language
+// header and passes the Catalog to next in the request context, use
+// FromContext to get it. The negotiated locale is sent back in the
+// Content-Language header.
+func (t Translations) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale, catalog := t.Negotiate(r.Header.Get("Accept-Language"))
+		w.Header().Add("Vary", "Accept-Language")
+		if locale != "" {
+			w.Header().Set("Content-Language", ParseLocale(locale).Tag())
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), catalog)))
+	})
+}
+
+type catalog_context_key struct{}
+
+// NewContext returns a copy of ctx that carries catalog.
+func NewContext(ctx context.Context, catalog Catalog) context.Context {
+	return context.WithValue(ctx, catalog_context_key{}, catalog)
+}
+
+// FromContext returns the Catalog stored in ctx by NewContext or Middleware.
+// If there is none, a NullCatalog is returned.
+func FromContext(ctx context.Context) Catalog {
+	catalog, ok := ctx.Value(catalog_context_key{}).(Catalog)
+	if !ok {
+		return nullcatalog{}
+	}
+	return catalog
+}
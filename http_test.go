@@ -0,0 +1,92 @@
+package gettext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	for header, expected := range map[string][]LanguageRange{
+		"": {},
+		"de": {
+			{Tag: "de", Quality: 1},
+		},
+		"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5": {
+			{Tag: "fr-CH", Quality: 1},
+			{Tag: "fr", Quality: 0.9},
+			{Tag: "en", Quality: 0.8},
+			{Tag: "de", Quality: 0.7},
+			{Tag: "*", Quality: 0.5},
+		},
+		"en;q=0.5, ja, de;q=0, fr;q=bogus, pt;q=0.5": {
+			{Tag: "ja", Quality: 1},
+			{Tag: "en", Quality: 0.5},
+			{Tag: "pt", Quality: 0.5},
+		},
+		"en;Q=0.5, de ; q = 0.7, fr;Q=0": {
+			{Tag: "de", Quality: 0.7},
+			{Tag: "en", Quality: 0.5},
+		},
+	} {
+		got := ParseAcceptLanguage(header)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q: expected %v, got %v", header, expected, got)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver)
+	for header, expected := range map[string]string{
+		"ja":                  "ja",
+		"de, ja;q=0.5":        "ja",
+		"en-GB, en;q=0.8":     "en_GB",
+		"en-US, fr;q=0.8":     "en",
+		"fr;q=0.1, en-AU":     "en",
+		"de, *":               "",
+		"":                    "",
+		"ja;q=0, fr-CA;q=0.3": "fr",
+	} {
+		locale, _ := translations.Negotiate(header)
+		assert_equal(t, locale, expected)
+	}
+	locale, catalog := translations.Negotiate("de-AT, en-GB;q=0.5")
+	assert_equal(t, locale, "en_GB")
+	assert_equal(t, catalog.Gettext("greeting"), "Good day")
+	assert_equal(t, catalog.NGettext("order %d beer", "order %d beers", 1), "%d beer please")
+
+	translations = NewTranslations("testdata/", "messages", my_resolver, WithDefaultLocale("en"))
+	locale, catalog = translations.Negotiate("de, *;q=0.1")
+	assert_equal(t, locale, "en")
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+	locale, catalog = translations.Negotiate("de")
+	assert_equal(t, locale, "en")
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+}
+
+func TestMiddleware(t *testing.T) {
+	translations := NewTranslations("testdata/", "messages", my_resolver)
+	handler := translations.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).Gettext("greeting")))
+	}))
+	for header, expected := range map[string][2]string{
+		"ja-JP, en;q=0.5": {"こんいちは", "ja"},
+		"en-GB":           {"Good day", "en-GB"},
+		"de":              {"greeting", ""},
+	} {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept-Language", header)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert_equal(t, recorder.Body.String(), expected[0])
+		assert_equal(t, recorder.Header().Get("Content-Language"), expected[1])
+		assert_equal(t, recorder.Header().Get("Vary"), "Accept-Language")
+	}
+}
+
+func TestFromContextWithoutCatalog(t *testing.T) {
+	request := httptest.NewRequest("GET", "/", nil)
+	assert_equal(t, FromContext(request.Context()).Gettext("greeting"), "greeting")
+}
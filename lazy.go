@@ -0,0 +1,292 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// mo_table looks messages up directly in the contents of a mo file, using its
+// hash table or, if it has none, a binary search on the sorted msgids. Only
+// the msgstrs that are looked up are copied out of data.
+type mo_table struct {
+	data        []byte
+	order       binary.ByteOrder
+	n           uint32
+	orig_index  uint32
+	trans_index uint32
+	hash_size   uint32
+	hash_index  uint32
+	sysdep      map[string]string // expanded system dependent messages
+	sysdep_keys []string          // their keys, in the order of the file
+}
+
+// new_mo_table checks that the tables and strings of the mo file in data are
+// within bounds, so lookups never have to.
+func new_mo_table(data []byte) (*mo_table, header, error) {
+	var header header
+	if len(data) < 28 {
+		return nil, header, errors.New("file too short for a mo header")
+	}
+	table := &mo_table{data: data}
+	switch binary.LittleEndian.Uint32(data) {
+	case le_magic:
+		table.order = binary.LittleEndian
+	case be_magic:
+		table.order = binary.BigEndian
+	default:
+		return nil, header, fmt.Errorf("wrong magic number 0x%08x", binary.LittleEndian.Uint32(data))
+	}
+	header = table.header()
+	if err := header.check(int64(len(data))); err != nil {
+		return nil, header, err
+	}
+	table.n = header.NumStrings
+	table.orig_index = header.MasterIndex
+	table.trans_index = header.TranslationsIndex
+	table.hash_size = table.uint32(20)
+	table.hash_index = table.uint32(24)
+	if uint64(table.hash_index)+4*uint64(table.hash_size) > uint64(len(data)) {
+		return nil, header, fmt.Errorf("hash table of size %d at offset %d exceeds the file size", table.hash_size, table.hash_index)
+	}
+	for i := uint32(0); i < table.n; i++ {
+		length, offset := table.len_off(table.orig_index, i)
+		if !lenoff_within(len_offset{Len: length, Off: offset}, int64(len(data))) {
+			return nil, header, fmt.Errorf("msgid %d exceeds the file size", i)
+		}
+		length, offset = table.len_off(table.trans_index, i)
+		if !lenoff_within(len_offset{Len: length, Off: offset}, int64(len(data))) {
+			return nil, header, fmt.Errorf("msgstr %d exceeds the file size", i)
+		}
+	}
+	if header.get_major_version() == 1 {
+		file := io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))
+		messages, err := read_sysdep_messages(file, table.order, new_decode_budget(file.Size()), sysdep_segment)
+		if err != nil {
+			return nil, header, err
+		}
+		table.sysdep = make(map[string]string, len(messages))
+		table.sysdep_keys = make([]string, len(messages))
+		for i, message := range messages {
+			msgid, _, _ := strings.Cut(message.key, "\x00")
+			table.sysdep_keys[i] = message.key
+			if message.ok {
+				table.sysdep[msgid] = message.str
+			}
+		}
+	}
+	return table, header, nil
+}
+
+func (table *mo_table) header() header {
+	return header{
+		Version:           table.uint32(4),
+		NumStrings:        table.uint32(8),
+		MasterIndex:       table.uint32(12),
+		TranslationsIndex: table.uint32(16),
+	}
+}
+
+func (table *mo_table) uint32(offset uint32) uint32 {
+	return table.order.Uint32(table.data[offset:])
+}
+
+// len_off returns the length and offset of string i of the table at index.
+func (table *mo_table) len_off(index uint32, i uint32) (uint32, uint32) {
+	return table.uint32(index + 8*i), table.uint32(index + 8*i + 4)
+}
+
+// msgid returns original string i up to its first NUL byte, that is without
+// the plural msgid. It points into data.
+func (table *mo_table) msgid(i uint32) []byte {
+	length, offset := table.len_off(table.orig_index, i)
+	s := table.data[offset : offset+length]
+	for j, c := range s {
+		if c == 0 {
+			return s[:j]
+		}
+	}
+	return s
+}
+
+// find returns a copy of the msgstr of key, the plural forms separated by NUL
+// bytes.
+func (table *mo_table) find(key string) (string, bool) {
+	i, ok := table.index(key)
+	if !ok {
+		msgstr, ok := table.sysdep[key]
+		return msgstr, ok
+	}
+	length, offset := table.len_off(table.trans_index, i)
+	msgstr := string(table.data[offset : offset+length])
+	// The finalizer unmaps data, so table must outlive the copy.
+	runtime.KeepAlive(table)
+	return msgstr, true
+}
+
+// index returns the position of key in the tables, like GNU gettext's
+// _nl_find_msg does.
+func (table *mo_table) index(key string) (uint32, bool) {
+	defer runtime.KeepAlive(table)
+	if table.hash_size > 2 {
+		hash := hash_string(key)
+		index := hash % table.hash_size
+		incr := 1 + hash%(table.hash_size-2)
+		for tries := uint32(0); tries < table.hash_size; tries++ {
+			nstr := table.uint32(table.hash_index + 4*index)
+			switch {
+			case nstr == 0:
+				return 0, false
+			case nstr <= table.n:
+				if string(table.msgid(nstr-1)) == key {
+					return nstr - 1, true
+				}
+			case nstr-table.n <= uint32(len(table.sysdep_keys)):
+				// Entries past the static messages are system dependent
+				// ones, find looks those up in sysdep.
+				msgid, _, _ := strings.Cut(table.sysdep_keys[nstr-table.n-1], "\x00")
+				if msgid == key {
+					if _, ok := table.sysdep[key]; ok {
+						return 0, false
+					}
+				}
+			}
+			if index >= table.hash_size-incr {
+				index -= table.hash_size - incr
+			} else {
+				index += incr
+			}
+		}
+		return 0, false
+	}
+	bottom, top := uint32(0), table.n
+	for bottom < top {
+		middle := bottom + (top-bottom)/2
+		switch cmp := strings.Compare(key, string(table.msgid(middle))); {
+		case cmp < 0:
+			top = middle
+		case cmp > 0:
+			bottom = middle + 1
+		default:
+			return middle, true
+		}
+	}
+	return 0, false
+}
+
+// ParseMOLazy is like ParseMOBytes, but instead of decoding all messages up
+// front, it looks them up in data when they are requested. This loads much
+// faster and takes less memory for large catalogs. data must not be modified
+// afterwards. Catalogs in other charsets than UTF-8 are decoded up front.
+func ParseMOLazy(data []byte) (Catalog, error) {
+	catalog, err := parse_mo_lazy(data)
+	return catalog, corrupt_catalog_error(err)
+}
+
+func parse_mo_lazy(data []byte) (mocatalog, error) {
+	catalog := mocatalog{info: make(map[string]string)}
+	table, header, err := new_mo_table(data)
+	catalog.header = header
+	if err != nil {
+		return catalog, err
+	}
+	catalog.table = table
+	info, _ := table.find("")
+	decoder, err := charset_decoder(header_charset(info))
+	if err != nil {
+		return catalog, err
+	}
+	if decoder != nil {
+		// Keys have to be decoded to be found, so decode everything.
+		return parse_mo(io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))))
+	}
+	err = catalog.read_info(info)
+	if err != nil {
+		return catalog, err
+	}
+	return catalog, catalog.check_table_forms()
+}
+
+// check_table_forms is check_forms for the plural messages of a lazy catalog.
+// Only the NUL bytes of the msgstrs are counted, nothing is copied.
+func (catalog mocatalog) check_table_forms() error {
+	table := catalog.table
+	defer runtime.KeepAlive(table)
+	if catalog.pluralforms == nil {
+		return nil
+	}
+	for i := uint32(0); i < table.n; i++ {
+		length, offset := table.len_off(table.orig_index, i)
+		key := table.data[offset : offset+length]
+		end := bytes.IndexByte(key, 0)
+		if end == -1 {
+			continue
+		}
+		length, offset = table.len_off(table.trans_index, i)
+		forms := bytes.Count(table.data[offset:offset+length], []byte{0}) + 1
+		if forms != catalog.nplurals {
+			return catalog.check_forms(string(key[:end]), forms)
+		}
+	}
+	for _, key := range table.sysdep_keys {
+		msgid, _, plural := strings.Cut(key, "\x00")
+		if msgstr, ok := table.sysdep[msgid]; ok && plural {
+			if err := catalog.check_forms(msgid, strings.Count(msgstr, "\x00")+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// OpenMO opens a mo file like ParseMOLazy. Where supported, the file is memory
+// mapped instead of read, so only the pages holding looked up messages are
+// ever loaded. The mapping is released once the Catalog is no longer used.
+// A memory mapped file must be replaced rather than overwritten in place.
+func OpenMO(name string) (Catalog, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse_mo_file(f)
+}
+
+func parse_mo_file(f *os.File) (Catalog, error) {
+	data, release, err := mmap(f)
+	if err != nil {
+		return nil, err
+	}
+	return parse_mo_mapped(data, release)
+}
+
+// parse_mo_mapped is ParseMOLazy for data that has to be released once the
+// catalog is no longer used.
+func parse_mo_mapped(data []byte, release func()) (Catalog, error) {
+	catalog, err := ParseMOLazy(data)
+	if err != nil && !errors.Is(err, ErrPluralFormCount) {
+		// The partly parsed catalog may refer to data, which is gone now.
+		release()
+		return nullcatalog{}, err
+	}
+	table := catalog.(mocatalog).table
+	if table == nil {
+		// Decoded up front, nothing refers to data anymore.
+		release()
+		return catalog, err
+	}
+	runtime.SetFinalizer(table, func(*mo_table) {
+		release()
+	})
+	return catalog, err
+}
+
+func read_file(f *os.File) (data []byte, release func(), err error) {
+	data, err = io.ReadAll(f)
+	return data, func() {}, err
+}
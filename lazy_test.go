@@ -0,0 +1,252 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// assert_same_catalog checks that lazy translates every message of po like
+// eager does.
+func assert_same_catalog(t *testing.T, name string, po *POFile, eager Catalog, lazy Catalog) {
+	if lazy.PluralForms().Plural != eager.PluralForms().Plural ||
+		lazy.Language() != eager.Language() || lazy.Charset() != eager.Charset() {
+		t.Errorf("%s: metadata differs", name)
+	}
+	for _, entry := range append(po.Entries, &POEntry{ID: "not translated", IDPlural: "not translated either"}) {
+		for _, n := range []uint32{0, 1, 2, 5} {
+			var got, expected string
+			var got_ok, expected_ok bool
+			switch {
+			case entry.HasContext && entry.Plural():
+				got, got_ok = lazy.NPLookup(entry.Context, entry.ID, entry.IDPlural, n)
+				expected, expected_ok = eager.NPLookup(entry.Context, entry.ID, entry.IDPlural, n)
+			case entry.HasContext:
+				got, got_ok = lazy.PLookup(entry.Context, entry.ID)
+				expected, expected_ok = eager.PLookup(entry.Context, entry.ID)
+			case entry.Plural():
+				got, got_ok = lazy.NLookup(entry.ID, entry.IDPlural, n)
+				expected, expected_ok = eager.NLookup(entry.ID, entry.IDPlural, n)
+			default:
+				got, got_ok = lazy.Lookup(entry.ID)
+				expected, expected_ok = eager.Lookup(entry.ID)
+			}
+			if got != expected || got_ok != expected_ok {
+				t.Errorf("%s: %q with n=%d: expected %q (%v), got %q (%v)", name, entry.ID, n, expected, expected_ok, got, got_ok)
+			}
+		}
+	}
+}
+
+func TestParseMOLazy(t *testing.T) {
+	for _, locale := range []string{"en", "ja", "en-no-plural-forms", "fr", "en_GB"} {
+		data := read_testdata(t, locale)
+		eager, err := ParseMOBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lazy, err := ParseMOLazy(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		po, err := DecompileMO(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_same_catalog(t, locale, po, eager, lazy)
+	}
+}
+
+func TestParseMOLazyLayouts(t *testing.T) {
+	po, err := ParsePO(strings.NewReader(full_po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eager, err := po.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, options := range []MOOptions{
+		{ByteOrder: binary.BigEndian},
+		{NoHashTable: true},
+		{ByteOrder: binary.BigEndian, NoHashTable: true},
+	} {
+		var out bytes.Buffer
+		if err := WriteMO(&out, po, options); err != nil {
+			t.Fatal(err)
+		}
+		lazy, err := ParseMOLazy(out.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_same_catalog(t, fmt.Sprintf("%+v", options), po, eager, lazy)
+	}
+}
+
+func TestParseMOLazyCorrupt(t *testing.T) {
+	data := read_testdata(t, "en")
+	for _, size := range []int{0, 27, 28, 40, len(data) - 2} {
+		_, err := ParseMOLazy(data[:size])
+		if !errors.Is(err, ErrCorruptCatalog) {
+			t.Errorf("truncated to %d bytes: expected a corrupt catalog error, got %v", size, err)
+		}
+	}
+	// Hash table entries pointing past the string tables are ignored.
+	broken := append([]byte{}, data...)
+	hash_size := binary.LittleEndian.Uint32(broken[20:])
+	hash_index := binary.LittleEndian.Uint32(broken[24:])
+	for i := uint32(0); i < hash_size; i++ {
+		binary.LittleEndian.PutUint32(broken[hash_index+4*i:], 0xffff)
+	}
+	catalog, err := ParseMOLazy(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("greeting"), "greeting")
+}
+
+func TestOpenMO(t *testing.T) {
+	catalog, err := OpenMO("testdata/fr/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.PGettext("verb", "Open"), "Ouvrir")
+	assert_equal(t, catalog.Language(), "fr")
+	_, err = OpenMO("testdata/xx/messages.mo")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestOpenMOError(t *testing.T) {
+	// Catalogs returned with an error stay usable after the file is unmapped
+	messages := []mo_message{
+		{key: "", str: "Plural-Forms: nplurals=2; plural=(n ^^ 1);\n"},
+		{key: "greeting", str: "Bonjour"},
+	}
+	name := filepath.Join(t.TempDir(), "messages.mo")
+	if err := os.WriteFile(name, encode_mo(messages, MOOptions{}), 0666); err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := OpenMO(name)
+	if !errors.Is(err, ErrPluralForms) {
+		t.Fatalf("expected a plural forms error, got %v", err)
+	}
+	runtime.GC()
+	assert_equal(t, catalog.Gettext("greeting"), "greeting")
+	assert_equal(t, catalog.NGettext("%d file", "%d files", 2), "%d files")
+}
+
+func TestOpenMOCollected(t *testing.T) {
+	// Catalogs dropped right after a lookup are unmapped while others are
+	// still being looked up in.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			runtime.GC()
+		}
+	}()
+	for i := 0; i < 2000; i++ {
+		catalog, err := OpenMO("testdata/fr/messages.mo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_equal(t, catalog.Gettext("greeting"), "Bonjour")
+	}
+	<-done
+}
+
+func TestWithLazyCatalogs(t *testing.T) {
+	mapfs := fstest.MapFS{
+		"ja/messages.mo": &fstest.MapFile{Data: read_testdata(t, "ja")},
+	}
+	for _, translations := range []Translations{
+		NewTranslations("testdata/", "messages", my_resolver, WithLazyCatalogs()),
+		NewTranslationsFS(mapfs, "messages", my_resolver, WithLazyCatalogs()),
+	} {
+		ja := translations.Locale("ja")
+		if ja.(mocatalog).table == nil {
+			t.Error("expected a lazy catalog")
+		}
+		assert_equal(t, ja.Gettext("greeting"), "こんいちは")
+		assert_equal(t, ja.PluralForms().Plural, "0")
+	}
+}
+
+// big_catalog returns a mo file with n messages, a tenth of them plural.
+func big_catalog(n int) []byte {
+	messages := []mo_message{{key: "", str: "Content-Type: text/plain; charset=UTF-8\nPlural-Forms: nplurals=2; plural=(n != 1);\n"}}
+	for i := 0; i < n; i++ {
+		message := mo_message{
+			key: fmt.Sprintf("message number %d", i),
+			str: fmt.Sprintf("translation of message number %d", i),
+		}
+		if i%10 == 0 {
+			message.key += "\x00plural"
+			message.str += "\x00plural translation"
+		}
+		messages = append(messages, message)
+	}
+	return encode_mo(messages, MOOptions{})
+}
+
+// benchmark_load measures loading a 40k message catalog. The retained-B
+// metric is how much heap the loaded catalog keeps alive, on top of the
+// contents of the mo file.
+func benchmark_load(b *testing.B, parse func([]byte) (Catalog, error)) {
+	data := big_catalog(40000)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	catalog, err := parse(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(catalog)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parse(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(after.HeapAlloc)-float64(before.HeapAlloc), "retained-B")
+}
+
+func BenchmarkLoadMO(b *testing.B) {
+	benchmark_load(b, ParseMOBytes)
+}
+
+func BenchmarkLoadMOLazy(b *testing.B) {
+	benchmark_load(b, ParseMOLazy)
+}
+
+func benchmark_gettext(b *testing.B, parse func([]byte) (Catalog, error)) {
+	catalog, err := parse(big_catalog(40000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		catalog.Gettext("message number 12345")
+	}
+}
+
+func BenchmarkGettextMO(b *testing.B) {
+	benchmark_gettext(b, ParseMOBytes)
+}
+
+func BenchmarkGettextMOLazy(b *testing.B) {
+	benchmark_gettext(b, ParseMOLazy)
+}
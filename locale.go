@@ -0,0 +1,213 @@
+package gettext
+
+import (
+	"strings"
+)
+
+// FallbackFunc returns the locales to look up translations in for the
+// requested locale, most specific first.
+type FallbackFunc func(locale string) []string
+
+// NoFallback only looks up translations in exactly the requested locale.
+func NoFallback(locale string) []string {
+	return []string{locale}
+}
+
+// DefaultFallback tries locale as given first, then normalizes it to the
+// POSIX form used for locale folders and falls back from the most specific to
+// the least specific variant, in the same order GNU gettext does. "de-AT"
+// yields de-AT, de_AT and de, "sr_RS.UTF-8@latin" yields sr_RS.UTF-8@latin,
+// sr_RS@latin, sr.UTF-8@latin, sr@latin, sr_RS.UTF-8, sr_RS, sr.UTF-8 and sr.
+// The C and POSIX locales are used as they are.
+func DefaultFallback(locale string) []string {
+	l := ParseLocale(locale)
+	if l.Language == "" || is_c_locale(locale) {
+		return NoFallback(locale)
+	}
+	candidates := []string{locale}
+	for _, c := range []LocaleID{
+		{Language: l.Language, Territory: l.Territory, Codeset: l.Codeset, Modifier: l.Modifier},
+		{Language: l.Language, Territory: l.Territory, Modifier: l.Modifier},
+		{Language: l.Language, Codeset: l.Codeset, Modifier: l.Modifier},
+		{Language: l.Language, Modifier: l.Modifier},
+		{Language: l.Language, Territory: l.Territory, Codeset: l.Codeset},
+		{Language: l.Language, Territory: l.Territory},
+		{Language: l.Language, Codeset: l.Codeset},
+		{Language: l.Language},
+	} {
+		if !contains(candidates, c.String()) {
+			candidates = append(candidates, c.String())
+		}
+	}
+	return candidates
+}
+
+// is_c_locale tells whether locale is the C or POSIX locale, with or without
+// a codeset.
+func is_c_locale(locale string) bool {
+	name, _, _ := strings.Cut(locale, ".")
+	return name == "C" || name == "POSIX"
+}
+
+// LocaleID is a locale identifier split into its parts.
+type LocaleID struct {
+	Language  string
+	Territory string
+	Codeset   string
+	Modifier  string
+}
+
+// BCP 47 script subtags that have a well known POSIX modifier. Other scripts
+// become a modifier of their own, "zh-Hant" is zh@hant.
+var script_modifiers = map[string]string{
+	"latn": "latin",
+	"cyrl": "cyrillic",
+}
+
+// ParseLocale parses both POSIX (ll_CC.codeset@modifier) and BCP 47
+// (ll-Script-CC) locale identifiers. Casing is normalized, so "pt-br" and
+// "pt_BR" both give a Language of "pt" and a Territory of "BR".
+func ParseLocale(locale string) LocaleID {
+	l := LocaleID{}
+	if i := strings.IndexByte(locale, '@'); i != -1 {
+		l.Modifier = locale[i+1:]
+		locale = locale[:i]
+	}
+	if i := strings.IndexByte(locale, '.'); i != -1 {
+		l.Codeset = locale[i+1:]
+		locale = locale[:i]
+	}
+	parts := strings.FieldsFunc(locale, func(r rune) bool {
+		return r == '_' || r == '-'
+	})
+	if len(parts) == 0 {
+		return l
+	}
+	l.Language = strings.ToLower(parts[0])
+	for _, part := range parts[1:] {
+		switch {
+		case len(part) == 4 && l.Territory == "":
+			if l.Modifier == "" {
+				script := strings.ToLower(part)
+				if modifier, ok := script_modifiers[script]; ok {
+					script = modifier
+				}
+				l.Modifier = script
+			}
+		case (len(part) == 2 || len(part) == 3) && l.Territory == "":
+			l.Territory = strings.ToUpper(part)
+		}
+	}
+	return l
+}
+
+// String formats the locale as ll_CC.codeset@modifier, leaving out the parts
+// that are empty.
+func (l LocaleID) String() string {
+	s := l.Language
+	if l.Territory != "" {
+		s += "_" + l.Territory
+	}
+	if l.Codeset != "" {
+		s += "." + l.Codeset
+	}
+	if l.Modifier != "" {
+		s += "@" + l.Modifier
+	}
+	return s
+}
+
+// Tag formats the language and territory of the locale as a BCP 47 language
+// tag such as "pt-BR".
+func (l LocaleID) Tag() string {
+	if l.Territory == "" {
+		return l.Language
+	}
+	return l.Language + "-" + l.Territory
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackcatalog looks up every message in each of its catalogs in turn and
+// uses the first translation found. The meta data is that of the first,
+// most specific, catalog.
+type fallbackcatalog []Catalog
+
+func (catalog fallbackcatalog) Language() string {
+	return catalog[0].Language()
+}
+
+func (catalog fallbackcatalog) Charset() string {
+	return catalog[0].Charset()
+}
+
+func (catalog fallbackcatalog) Header(key string) string {
+	return catalog[0].Header(key)
+}
+
+func (catalog fallbackcatalog) PluralForms() PluralForms {
+	return catalog[0].PluralForms()
+}
+
+func (catalog fallbackcatalog) Gettext(msgid string) string {
+	msgstr, _ := catalog.Lookup(msgid)
+	return msgstr
+}
+
+func (catalog fallbackcatalog) NGettext(msgid string, msgid_plural string, n uint32) string {
+	msgstr, _ := catalog.NLookup(msgid, msgid_plural, n)
+	return msgstr
+}
+
+func (catalog fallbackcatalog) PGettext(context string, msgid string) string {
+	msgstr, _ := catalog.PLookup(context, msgid)
+	return msgstr
+}
+
+func (catalog fallbackcatalog) NPGettext(context string, msgid string, msgid_plural string, n uint32) string {
+	msgstr, _ := catalog.NPLookup(context, msgid, msgid_plural, n)
+	return msgstr
+}
+
+func (catalog fallbackcatalog) Lookup(msgid string) (string, bool) {
+	for _, c := range catalog {
+		if msgstr, ok := c.Lookup(msgid); ok {
+			return msgstr, true
+		}
+	}
+	return nullcatalog{}.Lookup(msgid)
+}
+
+func (catalog fallbackcatalog) NLookup(msgid string, msgid_plural string, n uint32) (string, bool) {
+	for _, c := range catalog {
+		if msgstr, ok := c.NLookup(msgid, msgid_plural, n); ok {
+			return msgstr, true
+		}
+	}
+	return nullcatalog{}.NLookup(msgid, msgid_plural, n)
+}
+
+func (catalog fallbackcatalog) PLookup(context string, msgid string) (string, bool) {
+	for _, c := range catalog {
+		if msgstr, ok := c.PLookup(context, msgid); ok {
+			return msgstr, true
+		}
+	}
+	return nullcatalog{}.PLookup(context, msgid)
+}
+
+func (catalog fallbackcatalog) NPLookup(context string, msgid string, msgid_plural string, n uint32) (string, bool) {
+	for _, c := range catalog {
+		if msgstr, ok := c.NPLookup(context, msgid, msgid_plural, n); ok {
+			return msgstr, true
+		}
+	}
+	return nullcatalog{}.NPLookup(context, msgid, msgid_plural, n)
+}
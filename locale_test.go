@@ -0,0 +1,62 @@
+package gettext
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseLocale(t *testing.T) {
+	for input, expected := range map[string]LocaleID{
+		"de":                {Language: "de"},
+		"de_AT":             {Language: "de", Territory: "AT"},
+		"de-at":             {Language: "de", Territory: "AT"},
+		"pt_BR.UTF-8":       {Language: "pt", Territory: "BR", Codeset: "UTF-8"},
+		"sr_RS.UTF-8@latin": {Language: "sr", Territory: "RS", Codeset: "UTF-8", Modifier: "latin"},
+		"sr-Latn-RS":        {Language: "sr", Territory: "RS", Modifier: "latin"},
+		"zh-Hant-TW":        {Language: "zh", Territory: "TW", Modifier: "hant"},
+		"es-419":            {Language: "es", Territory: "419"},
+		"":                  {},
+	} {
+		got := ParseLocale(input)
+		if got != expected {
+			t.Errorf("%q: expected %+v, got %+v", input, expected, got)
+		}
+	}
+	assert_equal(t, ParseLocale("sr-latn-rs").String(), "sr_RS@latin")
+	assert_equal(t, ParseLocale("pt_br.utf-8").String(), "pt_BR.utf-8")
+}
+
+func TestDefaultFallback(t *testing.T) {
+	for input, expected := range map[string]string{
+		"de":                "de",
+		"de-AT":             "de-AT de_AT de",
+		"pt-BR":             "pt-BR pt_BR pt",
+		"pt_BR.UTF-8":       "pt_BR.UTF-8 pt_BR pt.UTF-8 pt",
+		"de_DE.UTF-8":       "de_DE.UTF-8 de_DE de.UTF-8 de",
+		"sr_RS.UTF-8@latin": "sr_RS.UTF-8@latin sr_RS@latin sr.UTF-8@latin sr@latin sr_RS.UTF-8 sr_RS sr.UTF-8 sr",
+		"ca@valencia":       "ca@valencia ca",
+		"zh-Hant-TW":        "zh-Hant-TW zh_TW@hant zh@hant zh_TW zh",
+		"zh-Hant":           "zh-Hant zh@hant zh",
+		"C":                 "C",
+		"C.UTF-8":           "C.UTF-8",
+		"POSIX":             "POSIX",
+		"":                  "",
+	} {
+		got := strings.Join(DefaultFallback(input), " ")
+		assert_equal(t, got, expected)
+	}
+}
+
+func TestLocaleAsGiven(t *testing.T) {
+	fr := read_testdata(t, "fr")
+	mapfs := fstest.MapFS{
+		"pt-BR/LC_MESSAGES/messages.mo":      &fstest.MapFile{Data: fr},
+		"zh_TW@hant/LC_MESSAGES/messages.mo": &fstest.MapFile{Data: fr},
+		"zh/LC_MESSAGES/messages.mo":         &fstest.MapFile{Data: read_testdata(t, "en")},
+	}
+	translations := NewTranslationsFS(mapfs, "messages", DefaultResolver)
+	assert_equal(t, translations.Locale("pt-BR").Gettext("greeting"), "Bonjour")
+	assert_equal(t, translations.Locale("zh-Hant-TW").Gettext("greeting"), "Bonjour")
+	assert_equal(t, translations.Locale("zh-Hans-CN").Gettext("greeting"), "Hello")
+}
@@ -0,0 +1,182 @@
+package gettext
+
+// MergeOptions controls how Merge updates translations.
+type MergeOptions struct {
+	// NoFuzzyMatching disables reusing translations of similar messages,
+	// like msgmerge --no-fuzzy-matching does.
+	NoFuzzyMatching bool
+}
+
+// Below this similarity, translations of other messages are not reused. This
+// is the threshold msgmerge uses.
+const fuzzy_threshold = 0.6
+
+// Merge updates the translations def to a new template ref, like msgmerge.
+// The result has the entries of ref in the same order, with the translations
+// of def:
+//
+//   - messages in both keep their translation and translator comments and
+//     get the references and extracted comments of ref
+//   - new messages get the translation of a similar message of def, marked
+//     fuzzy with the msgid it was translated from recorded as previous msgid,
+//     or no translation at all
+//   - translated messages of def that were not used for any message of ref
+//     become obsolete
+//
+// def and ref are not modified.
+func Merge(def *POFile, ref *POFile, options MergeOptions) *POFile {
+	result := &POFile{}
+	if def.Header != nil {
+		result.Header = def.Header.clone()
+	} else if ref.Header != nil {
+		result.Header = ref.Header.clone()
+	}
+	if date := ref.HeaderField("POT-Creation-Date"); date != "" && result.Header != nil {
+		result.SetHeaderField("POT-Creation-Date", date)
+	}
+	nplurals := def.nplurals()
+
+	by_key := map[string]*POEntry{}
+	candidates := []*POEntry{}
+	for _, entry := range def.Entries {
+		if _, ok := by_key[entry.Key()]; !ok || !entry.Obsolete {
+			by_key[entry.Key()] = entry
+		}
+		if entry.Translated() && !entry.HasFlag("fuzzy") {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	used := map[*POEntry]bool{}
+	for _, template := range ref.Entries {
+		if template.Obsolete {
+			continue
+		}
+		entry := template.clone()
+		entry.TranslatorComments = nil
+		entry.RemoveFlag("fuzzy")
+		entry.PreviousContext, entry.PreviousID, entry.PreviousIDPlural = "", "", ""
+		if old, ok := by_key[template.Key()]; ok {
+			used[old] = true
+			entry.TranslatorComments = append([]string(nil), old.TranslatorComments...)
+			entry.Str = adapt_forms(old.Str, entry.Plural(), nplurals)
+			if old.HasFlag("fuzzy") || old.Plural() != entry.Plural() {
+				entry.AddFlag("fuzzy")
+				entry.PreviousContext = old.PreviousContext
+				entry.PreviousID = old.PreviousID
+				entry.PreviousIDPlural = old.PreviousIDPlural
+			}
+		} else if similar := best_match(template, candidates, options); similar != nil {
+			used[similar] = true
+			entry.TranslatorComments = append([]string(nil), similar.TranslatorComments...)
+			entry.Str = adapt_forms(similar.Str, entry.Plural(), nplurals)
+			entry.AddFlag("fuzzy")
+			entry.PreviousContext = similar.Context
+			entry.PreviousID = similar.ID
+			entry.PreviousIDPlural = similar.IDPlural
+		} else {
+			entry.Str = adapt_forms(nil, entry.Plural(), nplurals)
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+
+	for _, old := range def.Entries {
+		if used[old] || !old.Translated() {
+			continue
+		}
+		entry := old.clone()
+		entry.Obsolete = true
+		entry.References = nil
+		entry.ExtractedComments = nil
+		result.Entries = append(result.Entries, entry)
+	}
+	return result
+}
+
+// best_match returns the translated entry most similar to template, if it is
+// similar enough.
+func best_match(template *POEntry, candidates []*POEntry, options MergeOptions) *POEntry {
+	if options.NoFuzzyMatching {
+		return nil
+	}
+	var best *POEntry
+	best_score := fuzzy_threshold
+	for _, candidate := range candidates {
+		if candidate.Context != template.Context {
+			continue
+		}
+		score := similarity(template.ID, candidate.ID, best_score)
+		if score >= best_score && (best == nil || score > best_score) {
+			best = candidate
+			best_score = score
+		}
+	}
+	return best
+}
+
+// similarity returns a value between 0 and 1 telling how alike a and b are,
+// based on the length of their longest common subsequence. Strings that cannot
+// reach minimum are rejected early with a score of 0.
+func similarity(a string, b string, minimum float64) float64 {
+	x, y := []rune(a), []rune(b)
+	total := len(x) + len(y)
+	if total == 0 {
+		return 1
+	}
+	shorter := len(x)
+	if len(y) < shorter {
+		shorter = len(y)
+	}
+	if float64(2*shorter)/float64(total) < minimum {
+		return 0
+	}
+	previous := make([]int, len(y)+1)
+	current := make([]int, len(y)+1)
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			if x[i-1] == y[j-1] {
+				current[j] = previous[j-1] + 1
+			} else if previous[j] > current[j-1] {
+				current[j] = previous[j]
+			} else {
+				current[j] = current[j-1]
+			}
+		}
+		previous, current = current, previous
+	}
+	return float64(2*previous[len(y)]) / float64(total)
+}
+
+// adapt_forms returns a copy of msgstrs with as many forms as an entry needs.
+func adapt_forms(msgstrs []string, plural bool, nplurals int) []string {
+	forms := 1
+	if plural {
+		forms = nplurals
+		if forms < 1 {
+			forms = 2
+		}
+	}
+	result := make([]string, forms)
+	copy(result, msgstrs)
+	if plural && len(msgstrs) == 1 {
+		for i := range result {
+			result[i] = msgstrs[0]
+		}
+	}
+	return result
+}
+
+// nplurals returns the number of plural forms declared in the header, or 0.
+func (po *POFile) nplurals() int {
+	return parse_nplurals(po.HeaderField("Plural-Forms"))
+}
+
+func (entry *POEntry) clone() *POEntry {
+	c := *entry
+	c.TranslatorComments = append([]string(nil), entry.TranslatorComments...)
+	c.ExtractedComments = append([]string(nil), entry.ExtractedComments...)
+	c.References = append([]string(nil), entry.References...)
+	c.Flags = append([]string(nil), entry.Flags...)
+	c.Str = append([]string(nil), entry.Str...)
+	return &c
+}
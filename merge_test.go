@@ -0,0 +1,212 @@
+package gettext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const merge_def = `msgid ""
+msgstr ""
+"Language: de\n"
+"POT-Creation-Date: 2016-01-01 00:00+0000\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+# Keep it short
+#: old.go:1
+msgid "Open file"
+msgstr "Datei öffnen"
+
+#: old.go:2
+msgid "Save the document"
+msgstr "Dokument speichern"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] "%d Dateien"
+
+#, fuzzy
+msgid "Quit"
+msgstr "Beenden"
+
+msgid "Removed message"
+msgstr "Entfernte Nachricht"
+
+msgid "Never translated"
+msgstr ""
+
+#~ msgid "Long gone"
+#~ msgstr "Lange weg"
+`
+
+const merge_ref = `msgid ""
+msgstr ""
+"POT-Creation-Date: 2016-02-02 00:00+0000\n"
+
+#. TRANSLATORS: menu entry
+#: main.go:10
+#, c-format
+msgid "Open file"
+msgstr ""
+
+#: main.go:11
+msgid "Save the documents"
+msgstr ""
+
+#: main.go:12
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+
+#: main.go:13
+msgid "Quit"
+msgstr ""
+
+#: main.go:14
+msgid "Something entirely different"
+msgid_plural "Some entirely different things"
+msgstr[0] ""
+msgstr[1] ""
+`
+
+func parse_po_string(t *testing.T, source string) *POFile {
+	po, err := ParsePO(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return po
+}
+
+func TestMerge(t *testing.T) {
+	def := parse_po_string(t, merge_def)
+	ref := parse_po_string(t, merge_ref)
+	merged := Merge(def, ref, MergeOptions{})
+
+	assert_equal(t, merged.HeaderField("Language"), "de")
+	assert_equal(t, merged.HeaderField("POT-Creation-Date"), "2016-02-02 00:00+0000")
+
+	expected := []*POEntry{
+		{
+			TranslatorComments: []string{"Keep it short"},
+			ExtractedComments:  []string{"TRANSLATORS: menu entry"},
+			References:         []string{"main.go:10"},
+			Flags:              []string{"c-format"},
+			ID:                 "Open file",
+			Str:                []string{"Datei öffnen"},
+		},
+		{
+			References: []string{"main.go:11"},
+			Flags:      []string{"fuzzy"},
+			PreviousID: "Save the document",
+			ID:         "Save the documents",
+			Str:        []string{"Dokument speichern"},
+		},
+		{
+			References: []string{"main.go:12"},
+			ID:         "%d file",
+			IDPlural:   "%d files",
+			Str:        []string{"%d Datei", "%d Dateien"},
+		},
+		{
+			References: []string{"main.go:13"},
+			Flags:      []string{"fuzzy"},
+			ID:         "Quit",
+			Str:        []string{"Beenden"},
+		},
+		{
+			References: []string{"main.go:14"},
+			ID:         "Something entirely different",
+			IDPlural:   "Some entirely different things",
+			Str:        []string{"", ""},
+		},
+		{
+			ID:       "Removed message",
+			Str:      []string{"Entfernte Nachricht"},
+			Obsolete: true,
+		},
+		{
+			ID:       "Long gone",
+			Str:      []string{"Lange weg"},
+			Obsolete: true,
+		},
+	}
+	if len(merged.Entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(merged.Entries))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(merged.Entries[i], expected[i]) {
+			t.Errorf("expected %+v, got %+v", expected[i], merged.Entries[i])
+		}
+	}
+
+	// The inputs are left alone
+	if !reflect.DeepEqual(def, parse_po_string(t, merge_def)) || !reflect.DeepEqual(ref, parse_po_string(t, merge_ref)) {
+		t.Error("Merge modified its arguments")
+	}
+}
+
+func TestMergeNoFuzzyMatching(t *testing.T) {
+	merged := Merge(parse_po_string(t, merge_def), parse_po_string(t, merge_ref), MergeOptions{NoFuzzyMatching: true})
+	save := merged.Entries[1]
+	if save.HasFlag("fuzzy") || save.Translated() || save.PreviousID != "" {
+		t.Errorf("expected an untranslated entry, got %+v", save)
+	}
+	// Without the fuzzy match, the old message becomes obsolete
+	found := false
+	for _, entry := range merged.Entries {
+		if entry.ID == "Save the document" && entry.Obsolete {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected the old message to be obsolete")
+	}
+}
+
+func TestMergePluralForms(t *testing.T) {
+	def := parse_po_string(t, `msgid ""
+msgstr ""
+"Plural-Forms: nplurals=3; plural=n==1 ? 0 : n==2 ? 1 : 2;\n"
+
+msgid "apple"
+msgstr "jabłko"
+`)
+	ref := parse_po_string(t, `msgid "apple"
+msgid_plural "apples"
+msgstr[0] ""
+msgstr[1] ""
+
+msgid "pear"
+msgid_plural "pears"
+msgstr[0] ""
+msgstr[1] ""
+`)
+	merged := Merge(def, ref, MergeOptions{})
+	apple := merged.Entries[0]
+	if !apple.HasFlag("fuzzy") || !reflect.DeepEqual(apple.Str, []string{"jabłko", "jabłko", "jabłko"}) {
+		t.Errorf("unexpected entry %+v", apple)
+	}
+	if pear := merged.Entries[1]; len(pear.Str) != 3 {
+		t.Errorf("expected 3 plural forms, got %+v", pear)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected float64
+	}{
+		{"", "", 1},
+		{"abc", "abc", 1},
+		{"abc", "xyz", 0},
+		{"abcd", "abce", 0.75},
+		{"ab", "abcdefghij", 0},
+	} {
+		got := similarity(c.a, c.b, 0.5)
+		if got != c.expected {
+			t.Errorf("similarity(%q, %q) = %f, expected %f", c.a, c.b, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package gettext
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Miss describes a message that was looked up but not translated.
+type Miss struct {
+	Locale      string
+	Domain      string
+	HasContext  bool
+	Context     string
+	MsgID       string
+	MsgIDPlural string
+}
+
+// MissingFunc is called for every message looked up without a translation.
+// It is called from the goroutine doing the lookup, so it should be fast and
+// safe for concurrent use.
+type MissingFunc func(miss Miss)
+
+// WithMissing makes the catalogs returned by Translations call missing for
+// every message they have no translation for.
+func WithMissing(missing MissingFunc) Option {
+	return func(t *Translations) {
+		t.missing = missing
+	}
+}
+
+// ReportMissing returns a Catalog that calls missing for every message that is
+// not translated in catalog. locale and domain are passed on in the Miss.
+func ReportMissing(catalog Catalog, locale string, domain string, missing MissingFunc) Catalog {
+	return reportingcatalog{
+		Catalog: catalog,
+		locale:  locale,
+		domain:  domain,
+		missing: missing,
+	}
+}
+
+type reportingcatalog struct {
+	Catalog
+	locale  string
+	domain  string
+	missing MissingFunc
+}
+
+func (catalog reportingcatalog) report(ok bool, has_context bool, context string, msgid string, msgid_plural string) {
+	if ok {
+		return
+	}
+	catalog.missing(Miss{
+		Locale:      catalog.locale,
+		Domain:      catalog.domain,
+		HasContext:  has_context,
+		Context:     context,
+		MsgID:       msgid,
+		MsgIDPlural: msgid_plural,
+	})
+}
+
+func (catalog reportingcatalog) Gettext(msgid string) string {
+	msgstr, _ := catalog.Lookup(msgid)
+	return msgstr
+}
+
+func (catalog reportingcatalog) NGettext(msgid string, msgid_plural string, n uint32) string {
+	msgstr, _ := catalog.NLookup(msgid, msgid_plural, n)
+	return msgstr
+}
+
+func (catalog reportingcatalog) PGettext(context string, msgid string) string {
+	msgstr, _ := catalog.PLookup(context, msgid)
+	return msgstr
+}
+
+func (catalog reportingcatalog) NPGettext(context string, msgid string, msgid_plural string, n uint32) string {
+	msgstr, _ := catalog.NPLookup(context, msgid, msgid_plural, n)
+	return msgstr
+}
+
+func (catalog reportingcatalog) Lookup(msgid string) (string, bool) {
+	msgstr, ok := catalog.Catalog.Lookup(msgid)
+	catalog.report(ok, false, "", msgid, "")
+	return msgstr, ok
+}
+
+func (catalog reportingcatalog) NLookup(msgid string, msgid_plural string, n uint32) (string, bool) {
+	msgstr, ok := catalog.Catalog.NLookup(msgid, msgid_plural, n)
+	catalog.report(ok, false, "", msgid, msgid_plural)
+	return msgstr, ok
+}
+
+func (catalog reportingcatalog) PLookup(context string, msgid string) (string, bool) {
+	msgstr, ok := catalog.Catalog.PLookup(context, msgid)
+	catalog.report(ok, true, context, msgid, "")
+	return msgstr, ok
+}
+
+func (catalog reportingcatalog) NPLookup(context string, msgid string, msgid_plural string, n uint32) (string, bool) {
+	msgstr, ok := catalog.Catalog.NPLookup(context, msgid, msgid_plural, n)
+	catalog.report(ok, true, context, msgid, msgid_plural)
+	return msgstr, ok
+}
+
+// MissingCollector counts misses in memory. Pass its Record method to
+// WithMissing or ReportMissing. The zero value is an empty collector. It is
+// safe for concurrent use.
+//
+// Every distinct miss is kept until Reset, so msgids built at runtime, such
+// as fmt.Sprintf results passed to Gettext, make it grow without bound. Set
+// Limit, or call Reset after collecting the misses.
+type MissingCollector struct {
+	// Limit is the number of distinct misses kept, 0 means no limit. Misses
+	// not kept yet are dropped once it is reached, see Dropped.
+	Limit int
+
+	mu      sync.Mutex
+	counts  map[Miss]int
+	dropped int
+}
+
+// MissingCount is a Miss along with how often it happened.
+type MissingCount struct {
+	Miss
+	Count int
+}
+
+// NewMissingCollector returns an empty MissingCollector.
+func NewMissingCollector() *MissingCollector {
+	return &MissingCollector{counts: map[Miss]int{}}
+}
+
+// Record counts a miss.
+func (c *MissingCollector) Record(miss Miss) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[Miss]int{}
+	}
+	if _, ok := c.counts[miss]; !ok && c.Limit > 0 && len(c.counts) >= c.Limit {
+		c.dropped++
+		return
+	}
+	c.counts[miss]++
+}
+
+// Dropped returns how many misses were not recorded because of the Limit.
+func (c *MissingCollector) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Reset forgets all misses recorded and dropped so far.
+func (c *MissingCollector) Reset() {
+	c.mu.Lock()
+	c.counts = nil
+	c.dropped = 0
+	c.mu.Unlock()
+}
+
+// Misses returns the misses recorded so far, the most frequent first.
+func (c *MissingCollector) Misses() []MissingCount {
+	c.mu.Lock()
+	misses := make([]MissingCount, 0, len(c.counts))
+	for miss, count := range c.counts {
+		misses = append(misses, MissingCount{Miss: miss, Count: count})
+	}
+	c.mu.Unlock()
+	sort.Slice(misses, func(i, j int) bool {
+		a, b := misses[i], misses[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return fmt.Sprint(a.Miss) < fmt.Sprint(b.Miss)
+	})
+	return misses
+}
+
+// Template returns the messages missed so far as a po template, the most
+// frequently missed first. The locales and domains a message was missed in
+// are listed in an extracted comment, so translators can prioritize.
+func (c *MissingCollector) Template() *POFile {
+	po := &POFile{}
+	po.SetHeaderField("MIME-Version", "1.0")
+	po.SetHeaderField("Content-Type", "text/plain; charset=UTF-8")
+	po.SetHeaderField("Content-Transfer-Encoding", "8bit")
+	entries := map[string]*POEntry{}
+	counts := map[*POEntry]int{}
+	details := map[*POEntry][]string{}
+	for _, miss := range c.Misses() {
+		entry := &POEntry{
+			HasContext: miss.HasContext,
+			Context:    miss.Context,
+			ID:         miss.MsgID,
+			IDPlural:   miss.MsgIDPlural,
+		}
+		if existing, ok := entries[entry.Key()]; ok {
+			entry = existing
+			if entry.IDPlural == "" {
+				entry.IDPlural = miss.MsgIDPlural
+			}
+		} else {
+			entries[entry.Key()] = entry
+			po.Entries = append(po.Entries, entry)
+		}
+		counts[entry] += miss.Count
+		where := miss.Locale
+		if miss.Domain != "" {
+			where += "/" + miss.Domain
+		}
+		details[entry] = append(details[entry], fmt.Sprintf("%s: %d", where, miss.Count))
+	}
+	for _, entry := range po.Entries {
+		entry.Str = []string{""}
+		if entry.Plural() {
+			entry.Str = []string{"", ""}
+		}
+		entry.ExtractedComments = []string{
+			fmt.Sprintf("misses: %d (%s)", counts[entry], strings.Join(details[entry], ", ")),
+		}
+	}
+	sort.SliceStable(po.Entries, func(i, j int) bool {
+		return counts[po.Entries[i]] > counts[po.Entries[j]]
+	})
+	return po
+}
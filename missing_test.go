@@ -0,0 +1,141 @@
+package gettext
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithMissing(t *testing.T) {
+	var misses []Miss
+	translations := NewTranslations("testdata/", "messages", my_resolver, WithMissing(func(miss Miss) {
+		misses = append(misses, miss)
+	}))
+	fr := translations.Locale("fr_FR")
+	assert_equal(t, fr.PGettext("verb", "Open"), "Ouvrir")
+	assert_equal(t, fr.Gettext("Close"), "Close")
+	assert_equal(t, fr.NPGettext("mail", "%d message", "%d messages", 2), "%d messages")
+	if len(misses) != 2 {
+		t.Fatalf("expected 2 misses, got %v", misses)
+	}
+	expected := []Miss{
+		{Locale: "fr_FR", Domain: "messages", MsgID: "Close"},
+		{
+			Locale:      "fr_FR",
+			Domain:      "messages",
+			HasContext:  true,
+			Context:     "mail",
+			MsgID:       "%d message",
+			MsgIDPlural: "%d messages",
+		},
+	}
+	if !reflect.DeepEqual(misses, expected) {
+		t.Errorf("expected %v, got %v", expected, misses)
+	}
+	if fr.Language() != "fr" {
+		t.Errorf("expected the metadata of fr, got %q", fr.Language())
+	}
+}
+
+func TestMissingNotFound(t *testing.T) {
+	collector := NewMissingCollector()
+	translations := NewTranslations("testdata/", "messages", my_resolver, WithMissing(collector.Record))
+	translations.Locale("xx").Gettext("greeting")
+	expected := []MissingCount{
+		{Miss: Miss{Locale: "xx", Domain: "messages", MsgID: "greeting"}, Count: 1},
+	}
+	if misses := collector.Misses(); !reflect.DeepEqual(misses, expected) {
+		t.Errorf("expected %v, got %v", expected, misses)
+	}
+}
+
+func TestMissingCollector(t *testing.T) {
+	collector := NewMissingCollector()
+	translations := NewTranslations("testdata/", "messages", my_resolver, WithMissing(collector.Record))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			translations.Locale("fr").Gettext("Close")
+			translations.Locale("ja").Gettext("Close")
+			translations.Locale("ja").NGettext("%d file", "%d files", 2)
+		}()
+	}
+	wg.Wait()
+	translations.Locale("ja").NGettext("%d file", "%d files", 1)
+	translations.Locale("fr").PGettext("menu", "Close")
+
+	misses := collector.Misses()
+	if len(misses) != 4 {
+		t.Fatalf("expected 4 distinct misses, got %v", misses)
+	}
+	if misses[0].MsgID != "%d file" || misses[0].Count != 11 {
+		t.Errorf("expected %%d file to be missed most, got %v", misses[0])
+	}
+	if misses[3].Context != "menu" || misses[3].Count != 1 {
+		t.Errorf("expected menu/Close to be missed least, got %v", misses[3])
+	}
+
+	var buf bytes.Buffer
+	if _, err := collector.Template().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pot := buf.String()
+	for _, expected := range []string{
+		"#. misses: 11 (ja/messages: 11)\nmsgid \"%d file\"\nmsgid_plural \"%d files\"\n",
+		"#. misses: 20 (fr/messages: 10, ja/messages: 10)\nmsgid \"Close\"\n",
+		"#. misses: 1 (fr/messages: 1)\nmsgctxt \"menu\"\nmsgid \"Close\"\n",
+	} {
+		if !strings.Contains(pot, expected) {
+			t.Errorf("expected template to contain %q, got:\n%s", expected, pot)
+		}
+	}
+	if strings.Index(pot, "misses: 20") > strings.Index(pot, "misses: 11") {
+		t.Errorf("expected most frequent misses first, got:\n%s", pot)
+	}
+
+	collector.Reset()
+	if len(collector.Misses()) != 0 {
+		t.Error("expected no misses after Reset")
+	}
+}
+
+func TestMissingCollectorZeroValue(t *testing.T) {
+	var collector MissingCollector
+	if len(collector.Misses()) != 0 {
+		t.Error("expected no misses")
+	}
+	collector.Record(Miss{Locale: "fr", Domain: "messages", MsgID: "Close"})
+	collector.Record(Miss{Locale: "fr", Domain: "messages", MsgID: "Close"})
+	misses := collector.Misses()
+	if len(misses) != 1 || misses[0].Count != 2 {
+		t.Errorf("expected Close to be missed twice, got %v", misses)
+	}
+	collector.Reset()
+	collector.Record(Miss{Locale: "fr", Domain: "messages", MsgID: "Open"})
+	if misses := collector.Misses(); len(misses) != 1 || misses[0].MsgID != "Open" {
+		t.Errorf("expected Open to be missed after Reset, got %v", misses)
+	}
+}
+
+func TestMissingCollectorLimit(t *testing.T) {
+	collector := MissingCollector{Limit: 2}
+	for _, msgid := range []string{"Open", "Close", "Open", "Save", "Close", "Quit"} {
+		collector.Record(Miss{Locale: "fr", Domain: "messages", MsgID: msgid})
+	}
+	misses := collector.Misses()
+	if len(misses) != 2 || misses[0].Count != 2 || misses[1].Count != 2 {
+		t.Errorf("expected Close and Open to be missed twice, got %v", misses)
+	}
+	if dropped := collector.Dropped(); dropped != 2 {
+		t.Errorf("expected 2 dropped misses, got %d", dropped)
+	}
+	collector.Reset()
+	collector.Record(Miss{Locale: "fr", Domain: "messages", MsgID: "Save"})
+	if len(collector.Misses()) != 1 || collector.Dropped() != 0 {
+		t.Errorf("expected Save to be recorded after Reset, got %v", collector.Misses())
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package gettext
+
+import (
+	"os"
+)
+
+// mmap reads all of f, as memory mapping is not supported on this platform.
+func mmap(f *os.File) (data []byte, release func(), err error) {
+	return read_file(f)
+}
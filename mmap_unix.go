@@ -0,0 +1,25 @@
+//go:build unix
+
+package gettext
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmap maps the contents of f into memory read only. release unmaps them.
+func mmap(f *os.File) (data []byte, release func(), err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 || int64(int(size)) != size {
+		return read_file(f)
+	}
+	data, err = syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return read_file(f)
+	}
+	return data, func() { syscall.Munmap(data) }, nil
+}
@@ -3,13 +3,29 @@ package gettext
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/ojii/gettext.go/pluralforms"
-	"log"
-	"os"
+	"io"
+	"strconv"
 	"strings"
 )
 
+// ErrCorruptCatalog is wrapped by the errors returned for malformed mo and po
+// files.
+var ErrCorruptCatalog = errors.New("corrupt catalog")
+
+// ErrPluralForms is wrapped by the errors returned for mo files with a
+// Plural-Forms header that cannot be compiled, or that does not match the
+// messages.
+var ErrPluralForms = errors.New("bad plural forms expression")
+
+// ErrPluralFormCount is wrapped, along with ErrPluralForms, by the errors
+// returned for catalogs with plural messages that do not have nplurals forms.
+// Unlike other errors, it comes with a catalog that can be used. NGettext
+// returns the untranslated msgid or msgid_plural for the forms missing.
+var ErrPluralFormCount = fmt.Errorf("%w: wrong number of forms", ErrPluralForms)
+
 const le_magic = 0x950412de
 const be_magic = 0xde120495
 
@@ -28,17 +44,104 @@ func (header header) get_minor_version() uint32 {
 	return header.Version & 0xffff
 }
 
+// check makes sure the version is supported and that the string tables fit
+// in a file of the given size.
+func (header header) check(size int64) error {
+	if (header.get_major_version() != 0) && (header.get_major_version() != 1) {
+		return fmt.Errorf("unsupported version %d.%d", header.get_major_version(), header.get_minor_version())
+	}
+	if uint64(header.MasterIndex)+8*uint64(header.NumStrings) > uint64(size) {
+		return fmt.Errorf("table of %d msgids at offset %d exceeds the file size", header.NumStrings, header.MasterIndex)
+	}
+	if uint64(header.TranslationsIndex)+8*uint64(header.NumStrings) > uint64(size) {
+		return fmt.Errorf("table of %d msgstrs at offset %d exceeds the file size", header.NumStrings, header.TranslationsIndex)
+	}
+	return nil
+}
+
+// lenoff_within tells whether the string at lenoff fits in a file of the
+// given size.
+func lenoff_within(lenoff len_offset, size int64) bool {
+	return uint64(lenoff.Off)+uint64(lenoff.Len) <= uint64(size)
+}
+
+// decode_budget limits how many bytes the strings of a mo file decode to in
+// total. Well-formed files store every string once, so they decode to less
+// than their size; the budget stops small files whose entries all point at
+// the same large string.
+type decode_budget struct {
+	left int64
+}
+
+func new_decode_budget(size int64) *decode_budget {
+	return &decode_budget{left: 2 * size}
+}
+
+// take spends n bytes of the budget.
+func (budget *decode_budget) take(n uint32) error {
+	if int64(n) > budget.left {
+		return errors.New("strings decode to more than twice the file size")
+	}
+	budget.left -= int64(n)
+	return nil
+}
+
 // Catalog of translations for a given locale.
 type Catalog interface {
 	Gettext(msgid string) string
 	NGettext(msgid string, msgid_plural string, n uint32) string
+	PGettext(context string, msgid string) string
+	NPGettext(context string, msgid string, msgid_plural string, n uint32) string
+	// Lookup is like Gettext, but also reports whether the message is
+	// translated. Without a translation, the msgid is returned.
+	Lookup(msgid string) (string, bool)
+	// NLookup is like NGettext, but also reports whether the message is
+	// translated.
+	NLookup(msgid string, msgid_plural string, n uint32) (string, bool)
+	// PLookup is like PGettext, but also reports whether the message is
+	// translated.
+	PLookup(context string, msgid string) (string, bool)
+	// NPLookup is like NPGettext, but also reports whether the message is
+	// translated.
+	NPLookup(context string, msgid string, msgid_plural string, n uint32) (string, bool)
+	// Language returns the value of the Language header.
+	Language() string
+	// Charset returns the charset declared in the Content-Type header.
+	Charset() string
+	// Header returns the value of a header field, such as "Last-Translator".
+	// Keys are case insensitive.
+	Header(key string) string
+	// PluralForms returns the parsed Plural-Forms header.
+	PluralForms() PluralForms
+}
+
+// PluralForms describes the Plural-Forms header of a catalog. It is the zero
+// value if the header is missing or lacks plural= or a valid nplurals=, in
+// which case the catalog uses the Germanic plural rule like GNU gettext does.
+type PluralForms struct {
+	// NPlurals is the number of plural forms of the language.
+	NPlurals int
+	// Plural is the expression as written in the header.
+	Plural string
+	// Expression is the compiled plural expression.
+	Expression pluralforms.Expression
+}
+
+// Separates the message context from the msgid in the keys of a mo file.
+const context_separator = "\x04"
+
+func context_key(context string, msgid string) string {
+	return context + context_separator + msgid
 }
 
 type mocatalog struct {
 	header      header
 	language    string
 	messages    map[string][]string
+	table       *mo_table // looked up instead of messages if not nil
 	pluralforms pluralforms.Expression
+	plural      string
+	nplurals    int
 	info        map[string]string
 	charset     string
 }
@@ -57,43 +160,178 @@ func (catalog nullcatalog) NGettext(msgid string, msgid_plural string, n uint32)
 	}
 }
 
+func (catalog nullcatalog) PGettext(context string, msgid string) string {
+	return msgid
+}
+
+func (catalog nullcatalog) NPGettext(context string, msgid string, msgid_plural string, n uint32) string {
+	return catalog.NGettext(msgid, msgid_plural, n)
+}
+
+func (catalog nullcatalog) Lookup(msgid string) (string, bool) {
+	return catalog.Gettext(msgid), false
+}
+
+func (catalog nullcatalog) NLookup(msgid string, msgid_plural string, n uint32) (string, bool) {
+	return catalog.NGettext(msgid, msgid_plural, n), false
+}
+
+func (catalog nullcatalog) PLookup(context string, msgid string) (string, bool) {
+	return catalog.PGettext(context, msgid), false
+}
+
+func (catalog nullcatalog) NPLookup(context string, msgid string, msgid_plural string, n uint32) (string, bool) {
+	return catalog.NPGettext(context, msgid, msgid_plural, n), false
+}
+
+func (catalog nullcatalog) Language() string {
+	return ""
+}
+
+func (catalog nullcatalog) Charset() string {
+	return ""
+}
+
+func (catalog nullcatalog) Header(key string) string {
+	return ""
+}
+
+func (catalog nullcatalog) PluralForms() PluralForms {
+	return PluralForms{}
+}
+
 func (catalog mocatalog) Gettext(msgid string) string {
-	msgstrs, ok := catalog.messages[msgid]
+	msgstr, _ := catalog.gettext(msgid, msgid)
+	return msgstr
+}
+
+func (catalog mocatalog) NGettext(msgid string, msgid_plural string, n uint32) string {
+	msgstr, _ := catalog.ngettext(msgid, msgid, msgid_plural, n)
+	return msgstr
+}
+
+func (catalog mocatalog) PGettext(context string, msgid string) string {
+	msgstr, _ := catalog.gettext(context_key(context, msgid), msgid)
+	return msgstr
+}
+
+func (catalog mocatalog) NPGettext(context string, msgid string, msgid_plural string, n uint32) string {
+	msgstr, _ := catalog.ngettext(context_key(context, msgid), msgid, msgid_plural, n)
+	return msgstr
+}
+
+func (catalog mocatalog) Lookup(msgid string) (string, bool) {
+	return catalog.gettext(msgid, msgid)
+}
+
+func (catalog mocatalog) NLookup(msgid string, msgid_plural string, n uint32) (string, bool) {
+	return catalog.ngettext(msgid, msgid, msgid_plural, n)
+}
+
+func (catalog mocatalog) PLookup(context string, msgid string) (string, bool) {
+	return catalog.gettext(context_key(context, msgid), msgid)
+}
+
+func (catalog mocatalog) NPLookup(context string, msgid string, msgid_plural string, n uint32) (string, bool) {
+	return catalog.ngettext(context_key(context, msgid), msgid, msgid_plural, n)
+}
+
+func (catalog mocatalog) Language() string {
+	return catalog.language
+}
+
+func (catalog mocatalog) Charset() string {
+	return catalog.charset
+}
+
+func (catalog mocatalog) Header(key string) string {
+	return catalog.info[strings.ToLower(key)]
+}
+
+func (catalog mocatalog) PluralForms() PluralForms {
+	return PluralForms{
+		NPlurals:   catalog.nplurals,
+		Plural:     catalog.plural,
+		Expression: catalog.pluralforms,
+	}
+}
+
+func (catalog mocatalog) gettext(key string, msgid string) (string, bool) {
+	msgstr, ok := catalog.lookup(key)
 	if !ok {
-		return msgid
+		return msgid, false
 	}
-	return msgstrs[0]
+	return msgstr, true
 }
 
-func (catalog mocatalog) NGettext(msgid string, msgid_plural string, n uint32) string {
-	msgstrs, ok := catalog.messages[msgid]
+func (catalog mocatalog) ngettext(key string, msgid string, msgid_plural string, n uint32) (string, bool) {
+	msgstr, ok := catalog.nlookup(key, n)
 	if !ok {
 		if n == 1 {
-			return msgid
+			return msgid, false
 		} else {
-			return msgid_plural
+			return msgid_plural, false
 		}
-	} else {
-		/* Bogus/missing pluralforms in mo */
-		if catalog.pluralforms == nil {
-			/* Use the Germanic plural rule.  */
-			if n == 1 {
-				return msgstrs[0]
-			} else {
-				return msgstrs[1]
-			}
+	}
+	return msgstr, true
+}
+
+func (catalog mocatalog) lookup(key string) (string, bool) {
+	if catalog.table != nil {
+		msgstr, ok := catalog.table.find(key)
+		msgstr, _, _ = strings.Cut(msgstr, "\x00")
+		return msgstr, ok
+	}
+	msgstrs, ok := catalog.messages[key]
+	if !ok {
+		return "", false
+	}
+	return msgstrs[0], true
+}
+
+// msgstrs returns the translations of key, more than one for plural messages.
+func (catalog mocatalog) msgstrs(key string) ([]string, bool) {
+	if catalog.table != nil {
+		msgstr, ok := catalog.table.find(key)
+		if !ok {
+			return nil, false
 		}
+		return strings.Split(msgstr, "\x00"), true
+	}
+	msgstrs, ok := catalog.messages[key]
+	return msgstrs, ok
+}
 
-		index := catalog.pluralforms.Eval(n)
-		if index > len(msgstrs) {
-			if n == 1 {
-				return msgid
-			} else {
-				return msgid_plural
-			}
+func (catalog mocatalog) nlookup(key string, n uint32) (string, bool) {
+	msgstrs, ok := catalog.msgstrs(key)
+	if !ok {
+		return "", false
+	}
+	index := catalog.plural_index(n)
+	if index >= len(msgstrs) {
+		// Too few forms: the catalog was parsed lazily, or it has no
+		// Plural-Forms and falls back to the Germanic rule's two forms
+		return "", false
+	}
+	return msgstrs[index], true
+}
+
+// plural_index returns the plural form to use for n. Like GNU gettext, it
+// falls back to the first form if the expression does not select a valid one.
+func (catalog mocatalog) plural_index(n uint32) int {
+	/* Bogus/missing pluralforms in mo */
+	if catalog.pluralforms == nil {
+		/* Use the Germanic plural rule.  */
+		if n == 1 {
+			return 0
 		}
-		return msgstrs[index]
+		return 1
+	}
+	index := catalog.pluralforms.Eval(n)
+	if index < 0 || index >= catalog.nplurals {
+		return 0
 	}
+	return index
 }
 
 type len_offset struct {
@@ -101,14 +339,23 @@ type len_offset struct {
 	Off uint32
 }
 
-func read_len_off(index uint32, file *os.File, order binary.ByteOrder) (len_offset, error) {
+// read_at fills buf from r at the given offset. Unlike a bare ReadAt, a short
+// read is always reported as an error.
+func read_at(r io.ReaderAt, buf []byte, off int64) error {
+	n, err := r.ReadAt(buf, off)
+	if n == len(buf) {
+		return nil
+	}
+	if err == io.EOF || err == nil {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func read_len_off(index uint32, r io.ReaderAt, order binary.ByteOrder) (len_offset, error) {
 	lenoff := len_offset{}
 	buf := make([]byte, 8)
-	_, err := file.Seek(int64(index), os.SEEK_SET)
-	if err != nil {
-		return lenoff, err
-	}
-	_, err = file.Read(buf)
+	err := read_at(r, buf, int64(index))
 	if err != nil {
 		return lenoff, err
 	}
@@ -120,13 +367,12 @@ func read_len_off(index uint32, file *os.File, order binary.ByteOrder) (len_offs
 	return lenoff, nil
 }
 
-func read_message(file *os.File, lenoff len_offset) (string, error) {
-	_, err := file.Seek(int64(lenoff.Off), os.SEEK_SET)
-	if err != nil {
-		return "", err
+func read_message(r io.ReaderAt, lenoff len_offset) (string, error) {
+	if lenoff.Len == 0 {
+		return "", nil
 	}
 	buf := make([]byte, lenoff.Len)
-	_, err = file.Read(buf)
+	err := read_at(r, buf, int64(lenoff.Off))
 	if err != nil {
 		return "", err
 	}
@@ -151,35 +397,193 @@ func (catalog *mocatalog) read_info(info string) error {
 		} else if len(lastk) != 0 {
 			catalog.info[lastk] += "\n" + item
 		}
-		if k == "content-type" {
-			catalog.charset = strings.Split(v, "charset=")[1]
+		if k == "language" {
+			catalog.language = v
+		} else if k == "content-type" {
+			catalog.charset = content_type_charset(v)
 		} else if k == "plural-forms" {
-			p := strings.Split(v, ";")[1]
-			s := strings.Split(p, "plural=")[1]
-			expr, err := pluralforms.Compile(s)
+			if _, ok := plural_expression(v); !ok || parse_nplurals(v) == 0 {
+				// Like GNU gettext, use the Germanic plural rule
+				continue
+			}
+			forms, err := ParsePluralForms(v)
 			if err != nil {
 				return err
 			}
-			catalog.pluralforms = expr
+			catalog.pluralforms = forms.Expression
+			catalog.plural = forms.Plural
+			catalog.nplurals = forms.NPlurals
 		}
 	}
 	return nil
 }
 
-// ParseMO parses a mo file into a Catalog if possible.
-func ParseMO(file *os.File) (Catalog, error) {
-	var order binary.ByteOrder
-	header := header{}
+// ParsePluralForms parses the value of a Plural-Forms header, such as
+// "nplurals=2; plural=n != 1;". Like msgfmt, it checks that the expression
+// selects one of the nplurals forms for n up to 1000.
+func ParsePluralForms(plural_forms string) (PluralForms, error) {
+	s, ok := plural_expression(plural_forms)
+	if !ok {
+		return PluralForms{}, fmt.Errorf("%w: no plural= in %q", ErrPluralForms, plural_forms)
+	}
+	nplurals := parse_nplurals(plural_forms)
+	if nplurals == 0 {
+		return PluralForms{}, fmt.Errorf("%w: no valid nplurals= in %q", ErrPluralForms, plural_forms)
+	}
+	expr, err := pluralforms.Compile(s)
+	if err == nil {
+		err = check_plural_range(expr, nplurals)
+	}
+	if err != nil {
+		return PluralForms{}, fmt.Errorf("%w %q: %w", ErrPluralForms, s, err)
+	}
+	return PluralForms{NPlurals: nplurals, Plural: strings.TrimSpace(s), Expression: expr}, nil
+}
+
+// check_plural_range checks, like msgfmt, that expr selects one of the
+// nplurals forms for n up to 1000.
+func check_plural_range(expr pluralforms.Expression, nplurals int) error {
+	for n := uint32(0); n <= 1000; n++ {
+		index := expr.Eval(n)
+		if index < 0 {
+			return fmt.Errorf("cannot be evaluated for n = %d", n)
+		}
+		if index >= nplurals {
+			return fmt.Errorf("selects form %d for n = %d, but nplurals=%d", index, n, nplurals)
+		}
+	}
+	return nil
+}
+
+// check_forms checks that a plural message has a translation for each of the
+// plural forms. key is the message's key, without its plural msgid.
+func (catalog mocatalog) check_forms(key string, forms int) error {
+	if catalog.pluralforms == nil || forms == catalog.nplurals {
+		return nil
+	}
+	msgid := key
+	if context, id, ok := strings.Cut(key, context_separator); ok {
+		msgid = fmt.Sprintf("%s (context %q)", strconv.Quote(id), context)
+	} else {
+		msgid = strconv.Quote(msgid)
+	}
+	return fmt.Errorf("%w: %s has %d plural forms, but nplurals=%d", ErrPluralFormCount, msgid, forms, catalog.nplurals)
+}
+
+// plural_expression returns the plural= part of a Plural-Forms header.
+func plural_expression(plural_forms string) (string, bool) {
+	for _, part := range strings.Split(plural_forms, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if ok && strings.TrimSpace(k) == "plural" {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+// parse_nplurals returns the nplurals of a Plural-Forms header, or 0.
+func parse_nplurals(plural_forms string) int {
+	for _, part := range strings.Split(plural_forms, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if ok && strings.TrimSpace(k) == "nplurals" {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// ParseMO parses a mo file into a Catalog if possible. The whole file is read
+// into memory first, use ParseMOReaderAt to parse from random access storage.
+// Messages are converted to UTF-8 from the charset declared in the header, see
+// RegisterCharset.
+func ParseMO(r io.Reader) (Catalog, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMOBytes(data)
+}
+
+// ParseMOBytes parses the contents of a mo file into a Catalog if possible.
+// See ErrPluralFormCount for the one error the Catalog can be used with.
+func ParseMOBytes(data []byte) (Catalog, error) {
+	return ParseMOReaderAt(bytes.NewReader(data), int64(len(data)))
+}
+
+// ParseMOReaderAt parses a mo file of the given size into a Catalog if
+// possible. No data is read past size.
+func ParseMOReaderAt(r io.ReaderAt, size int64) (Catalog, error) {
+	catalog, err := parse_mo(io.NewSectionReader(r, 0, size))
+	return catalog, corrupt_catalog_error(err)
+}
+
+// corrupt_catalog_error wraps errors caused by a malformed mo file with
+// ErrCorruptCatalog.
+func corrupt_catalog_error(err error) error {
+	if err != nil && !errors.Is(err, ErrPluralForms) && !errors.Is(err, ErrUnknownCharset) {
+		err = fmt.Errorf("%w: %w", ErrCorruptCatalog, err)
+	}
+	return err
+}
+
+func parse_mo(file *io.SectionReader) (mocatalog, error) {
 	catalog := mocatalog{
-		header:   header,
 		info:     make(map[string]string),
 		messages: make(map[string][]string),
 	}
-	magic := make([]byte, 4)
-	_, err := file.Read(magic)
+	header, messages, err := read_mo(file, sysdep_segment)
+	catalog.header = header
 	if err != nil {
 		return catalog, err
 	}
+	err = decode_messages(messages)
+	if err != nil {
+		return catalog, err
+	}
+	for _, message := range messages {
+		if message.key == "" {
+			err = catalog.read_info(message.str)
+			if err != nil {
+				return catalog, err
+			}
+		}
+		if strings.Contains(message.key, "\x00") {
+			// Plural!
+			msgidsingular := strings.Split(message.key, "\x00")[0]
+			translations := strings.Split(message.str, "\x00")
+			catalog.messages[msgidsingular] = translations
+		} else {
+			catalog.messages[message.key] = []string{message.str}
+		}
+	}
+	// Checked once the header has been read, wherever it is in the file
+	for _, message := range messages {
+		if msgid, _, plural := strings.Cut(message.key, "\x00"); plural {
+			err = catalog.check_forms(msgid, len(catalog.messages[msgid]))
+			if err != nil {
+				return catalog, err
+			}
+		}
+	}
+	return catalog, nil
+}
+
+// read_mo reads the raw messages of a mo file, including the expanded system
+// dependent ones. Keys are the msgids, prefixed by their context and followed
+// by their plural msgid, the plural msgstrs are separated by NUL bytes. The
+// system dependent messages come last, expanded with segment.
+func read_mo(file *io.SectionReader, segment func(name string) (string, bool)) (header, []mo_message, error) {
+	var order binary.ByteOrder
+	header := header{}
+	messages := []mo_message{}
+	magic := make([]byte, 4)
+	err := read_at(file, magic, 0)
+	if err != nil {
+		return header, messages, err
+	}
 	magic_number := binary.LittleEndian.Uint32(magic)
 	switch magic_number {
 	case le_magic:
@@ -187,59 +591,104 @@ func ParseMO(file *os.File) (Catalog, error) {
 	case be_magic:
 		order = binary.BigEndian
 	default:
-		return catalog, fmt.Errorf("Wrong magic %d", magic_number)
+		return header, messages, fmt.Errorf("wrong magic number 0x%08x", magic_number)
 	}
-	raw_headers := make([]byte, 32)
-	_, err = file.Read(raw_headers)
+	raw_headers := make([]byte, binary.Size(header))
+	err = read_at(file, raw_headers, 4)
 	if err != nil {
-		return catalog, err
+		return header, messages, err
 	}
 	buffer := bytes.NewBuffer(raw_headers)
 	err = binary.Read(buffer, order, &header)
 	if err != nil {
-		return catalog, err
+		return header, messages, err
 	}
-	if (header.get_major_version() != 0) && (header.get_major_version() != 1) {
-		log.Printf("major %d minor %d", header.get_major_version(), header.get_minor_version())
-		return catalog, fmt.Errorf("Unsupported version: %d.%d", header.get_major_version(), header.get_minor_version())
+	err = header.check(file.Size())
+	if err != nil {
+		return header, messages, err
 	}
 	current_master_index := header.MasterIndex
 	current_transl_index := header.TranslationsIndex
+	budget := new_decode_budget(file.Size())
 	var index uint32 = 0
 	for ; index < header.NumStrings; index++ {
 		mlenoff, err := read_len_off(current_master_index, file, order)
 		if err != nil {
-			return catalog, err
+			return header, messages, err
 		}
 		tlenoff, err := read_len_off(current_transl_index, file, order)
 		if err != nil {
-			return catalog, err
+			return header, messages, err
+		}
+		if !lenoff_within(mlenoff, file.Size()) {
+			return header, messages, fmt.Errorf("msgid %d exceeds the file size", index)
+		}
+		if !lenoff_within(tlenoff, file.Size()) {
+			return header, messages, fmt.Errorf("msgstr %d exceeds the file size", index)
+		}
+		if err := budget.take(mlenoff.Len); err != nil {
+			return header, messages, err
+		}
+		if err := budget.take(tlenoff.Len); err != nil {
+			return header, messages, err
 		}
 		msgid, err := read_message(file, mlenoff)
 		if err != nil {
-			return catalog, nil
+			return header, messages, err
 		}
 		msgstr, err := read_message(file, tlenoff)
 		if err != nil {
-			return catalog, err
-		}
-		if mlenoff.Len == 0 {
-			err = catalog.read_info(msgstr)
-			if err != nil {
-				return catalog, err
-			}
-		}
-		if strings.Contains(msgid, "\x00") {
-			// Plural!
-			msgidsingular := strings.Split(msgid, "\x00")[0]
-			translations := strings.Split(msgstr, "\x00")
-			catalog.messages[msgidsingular] = translations
-		} else {
-			catalog.messages[msgid] = []string{msgstr}
+			return header, messages, err
 		}
+		messages = append(messages, mo_message{key: msgid, str: msgstr})
 
 		current_master_index += 8
 		current_transl_index += 8
 	}
-	return catalog, nil
+	if header.get_major_version() == 1 {
+		sysdep, err := read_sysdep(file, order, budget, segment)
+		if err != nil {
+			return header, messages, err
+		}
+		messages = append(messages, sysdep...)
+	}
+	return header, messages, nil
+}
+
+// DecompileMO turns the contents of a mo file back into a POFile, like
+// msgunfmt does. System dependent strings keep their segments, "%<PRIu64>",
+// and are flagged c-format, so msgfmt compiles them the same way again.
+func DecompileMO(data []byte) (*POFile, error) {
+	file := io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))
+	header, messages, err := read_mo(file, sysdep_placeholder)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptCatalog, err)
+	}
+	po := &POFile{}
+	for i, message := range messages {
+		entry := &POEntry{}
+		if uint32(i) >= header.NumStrings {
+			entry.Flags = []string{"c-format"}
+		}
+		key := message.key
+		if context, msgid, ok := strings.Cut(key, context_separator); ok {
+			entry.HasContext = true
+			entry.Context = context
+			key = msgid
+		}
+		msgid, msgid_plural, plural := strings.Cut(key, "\x00")
+		entry.ID = msgid
+		if plural {
+			entry.IDPlural = msgid_plural
+			entry.Str = strings.Split(message.str, "\x00")
+		} else {
+			entry.Str = []string{message.str}
+		}
+		if entry.ID == "" && !entry.HasContext && po.Header == nil {
+			po.Header = entry
+		} else {
+			po.Entries = append(po.Entries, entry)
+		}
+	}
+	return po, nil
 }
@@ -1,8 +1,12 @@
 package gettext
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -94,3 +98,482 @@ func TestJaNGettext(t *testing.T) {
 		"ビールを2杯ください",
 	)
 }
+
+func TestParseMOBytes(t *testing.T) {
+	data, err := os.ReadFile("testdata/ja/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMOBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("greeting"), "こんいちは")
+	assert_equal(t,
+		fmt.Sprintf(catalog.NGettext("order %d beer", "order %d beers", 2), 2),
+		"ビールを2杯ください",
+	)
+}
+
+func TestParseMOReader(t *testing.T) {
+	data, err := os.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bytes.Buffer only implements io.Reader, not io.ReaderAt
+	catalog, err := ParseMO(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+}
+
+func TestParseMOReaderAt(t *testing.T) {
+	file, err := os.Open("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMOReaderAt(file, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("greeting"), "Hello")
+	assert_equal(t,
+		fmt.Sprintf(catalog.NGettext("order %d beer", "order %d beers", 1), 1),
+		"1 beer please",
+	)
+}
+
+func TestParseMOTruncated(t *testing.T) {
+	data, err := os.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 3, 20, len(data) - 2} {
+		_, err = ParseMOBytes(data[:size])
+		if err == nil {
+			t.Errorf("expected error parsing mo file truncated to %d bytes", size)
+		}
+	}
+}
+
+func TestParseMOBounds(t *testing.T) {
+	data, err := os.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, field := range map[string]int{
+		"number of strings":          8,
+		"msgid table offset":         12,
+		"msgstr table offset":        16,
+		"length of the first msgid":  28,
+		"offset of the first msgstr": int(binary.LittleEndian.Uint32(data[16:])) + 4,
+	} {
+		broken := append([]byte{}, data...)
+		binary.LittleEndian.PutUint32(broken[field:], 0xfffffff0)
+		for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+			_, err := parse(broken)
+			if !errors.Is(err, ErrCorruptCatalog) {
+				t.Errorf("%s out of bounds: expected a corrupt catalog error, got %v", name, err)
+			}
+		}
+	}
+}
+
+// shared_string_mo returns a mo file whose entries all use the same string
+// of the given length as their msgid and msgstr.
+func shared_string_mo(entries int, length int) []byte {
+	str := 28 + 16*entries
+	data := make([]byte, str+length+1)
+	for i, v := range []int{le_magic, 0, entries, 28, 28 + 8*entries} {
+		binary.LittleEndian.PutUint32(data[4*i:], uint32(v))
+	}
+	for i := 0; i < 2*entries; i++ {
+		binary.LittleEndian.PutUint32(data[28+8*i:], uint32(length))
+		binary.LittleEndian.PutUint32(data[32+8*i:], uint32(str))
+	}
+	copy(data[str:], bytes.Repeat([]byte("a"), length))
+	return data
+}
+
+func TestParseMOSharedStrings(t *testing.T) {
+	data := shared_string_mo(2048, 32768)
+	_, err := ParseMOBytes(data)
+	if !errors.Is(err, ErrCorruptCatalog) {
+		t.Fatalf("expected a corrupt catalog error, got %v", err)
+	}
+	assert_equal(t, "corrupt catalog: strings decode to more than twice the file size", err.Error())
+	if _, err := DecompileMO(data); err == nil {
+		t.Error("expected DecompileMO to fail")
+	}
+	// A few shared strings are fine
+	catalog, err := ParseMOBytes(shared_string_mo(2, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, strings.Repeat("a", 40), catalog.Gettext(strings.Repeat("a", 40)))
+}
+
+func TestParseMOHeaderFields(t *testing.T) {
+	for info, expected := range map[string]error{
+		"Content-Type: text/plain\n":                                      nil,
+		"Plural-Forms: nplurals=2; plural=n != 1\n":                       nil,
+		"Plural-Forms: plural=n != 1\n":                                   nil,
+		"Plural-Forms: nplurals=two; plural=n != 1;\n":                    nil,
+		"Plural-Forms: nplurals=2\n":                                      nil,
+		"Plural-Forms: nplurals=2; plural=n % 0 == 1 ? 1 : 0;\n":          ErrPluralForms,
+		"Plural-Forms: nplurals=2; plural=n == 1 ? 0 : n == 2 ? 1 : 2;\n": ErrPluralForms,
+		"Plural-Forms: nplurals=2; plural=n / (n - 1);\n":                 ErrPluralForms,
+		"Plural-Forms: nplurals=2; plural=n > 1000 ? 2 : n != 1;\n":       nil,
+	} {
+		data := encode_mo([]mo_message{{key: "", str: info}}, MOOptions{})
+		for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+			catalog, err := parse(data)
+			if !errors.Is(err, expected) || (err == nil) != (expected == nil) {
+				t.Errorf("%q: expected %v, got %v", info, expected, err)
+			}
+			if err == nil {
+				assert_equal(t, catalog.Charset(), "")
+			}
+		}
+	}
+}
+
+func TestParseMOPluralRange(t *testing.T) {
+	for info, expected := range map[string]string{
+		"Plural-Forms: nplurals=2; plural=n == 1 ? 0 : n == 2 ? 1 : 2;\n": `bad plural forms expression "n == 1 ? 0 : n == 2 ? 1 : 2": selects form 2 for n = 0, but nplurals=2`,
+		"Plural-Forms: nplurals=2; plural=n / (n - 1);\n":                 `bad plural forms expression "n / (n - 1)": cannot be evaluated for n = 1`,
+	} {
+		_, err := ParseMOBytes(encode_mo([]mo_message{{key: "", str: info}}, MOOptions{}))
+		if err == nil {
+			t.Errorf("%q: parsed", info)
+			continue
+		}
+		assert_equal(t, expected, err.Error())
+	}
+}
+
+func TestParsePluralForms(t *testing.T) {
+	forms, err := ParsePluralForms(" nplurals=3; plural=(n==1 ? 0 : n==2 ? 1 : 2);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forms.NPlurals != 3 || forms.Expression.Eval(2) != 1 {
+		t.Errorf("unexpected plural forms %+v", forms)
+	}
+	assert_equal(t, "(n==1 ? 0 : n==2 ? 1 : 2)", forms.Plural)
+	for _, plural_forms := range []string{
+		"nplurals=2;",
+		"plural=n != 1;",
+		"nplurals=2; plural=n %;",
+		"nplurals=2; plural=n % 3;",
+	} {
+		if _, err := ParsePluralForms(plural_forms); !errors.Is(err, ErrPluralForms) {
+			t.Errorf("%q: expected a plural forms error, got %v", plural_forms, err)
+		}
+	}
+}
+
+func TestPluralFormsComparable(t *testing.T) {
+	fr := read_testdata(t, "fr")
+	a, err := ParseMOBytes(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseMOLazy(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.PluralForms() != b.PluralForms() {
+		t.Errorf("expected %v and %v to be equal", a.PluralForms(), b.PluralForms())
+	}
+	custom, err := ParsePluralForms("nplurals=3; plural=n % 3;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.PluralForms() == custom || custom != custom {
+		t.Errorf("unexpected comparison of %v and %v", a.PluralForms(), custom)
+	}
+}
+
+func TestParseMOPluralFormCount(t *testing.T) {
+	messages := []mo_message{
+		{key: "", str: "Plural-Forms: nplurals=3; plural=n == 1 ? 0 : n == 2 ? 1 : 2;\n"},
+		{key: "%d file\x00%d files", str: "%d Datei\x00%d Dateien"},
+		{key: "verb\x04%d file\x00%d files", str: "%d Datei\x00%d Dateien\x00%d Dateien"},
+	}
+	data := encode_mo(messages, MOOptions{})
+	po, err := DecompileMO(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The catalogs are reported, but can be used
+	for name, parse := range map[string]func([]byte) (Catalog, error){
+		"ParseMOBytes": ParseMOBytes,
+		"ParseMOLazy":  ParseMOLazy,
+		"Catalog":      func([]byte) (Catalog, error) { return po.Catalog() },
+	} {
+		catalog, err := parse(data)
+		if !errors.Is(err, ErrPluralFormCount) || !errors.Is(err, ErrPluralForms) {
+			t.Fatalf("%s: expected a plural form count error, got %v", name, err)
+		}
+		assert_equal(t, `bad plural forms expression: wrong number of forms: "%d file" has 2 plural forms, but nplurals=3`, err.Error())
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 2), "%d Dateien")
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 3), "%d files")
+		assert_equal(t, catalog.NPGettext("verb", "%d file", "%d files", 3), "%d Dateien")
+	}
+
+	messages[1].str = "%d Datei\x00%d Dateien\x00%d Dateien"
+	messages[2].str = "%d Datei"
+	for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+		_, err = parse(encode_mo(messages, MOOptions{}))
+		assert_equal(t, `bad plural forms expression: wrong number of forms: "%d file" (context "verb") has 1 plural forms, but nplurals=3`, err.Error())
+	}
+}
+
+func TestNGettextPluralFallback(t *testing.T) {
+	// Past the range checked when loading, invalid forms fall back to the first
+	messages := []mo_message{
+		{key: "", str: "Plural-Forms: nplurals=2; plural=n == 2000 ? n / (n - n) : n > 1000 ? 5 : n != 1;\n"},
+		{key: "%d file\x00%d files", str: "%d Datei\x00%d Dateien"},
+	}
+	for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+		catalog, err := parse(encode_mo(messages, MOOptions{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 1), "%d Datei")
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 2), "%d Dateien")
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 2000), "%d Datei")
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 3000), "%d Datei")
+	}
+}
+
+func TestNGettextNoNPlurals(t *testing.T) {
+	// Like GNU gettext, a Plural-Forms header without nplurals= or plural= is
+	// ignored
+	for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+		for _, plural_forms := range []string{"plural=n > 1;", "nplurals=3;"} {
+			messages := []mo_message{
+				{key: "", str: "Plural-Forms: " + plural_forms + "\n"},
+				{key: "%d file\x00%d files", str: "%d fichier\x00%d fichiers"},
+				{key: "%d folder\x00%d folders", str: "%d dossier"},
+			}
+			catalog, err := parse(encode_mo(messages, MOOptions{}))
+			if err != nil {
+				t.Fatalf("%q: %v", plural_forms, err)
+			}
+			if catalog.PluralForms() != (PluralForms{}) {
+				t.Errorf("expected no plural forms, got %v", catalog.PluralForms())
+			}
+			assert_equal(t, catalog.NGettext("%d file", "%d files", 0), "%d fichiers")
+			assert_equal(t, catalog.NGettext("%d file", "%d files", 1), "%d fichier")
+			assert_equal(t, catalog.NGettext("%d folder", "%d folders", 1), "%d dossier")
+			assert_equal(t, catalog.NGettext("%d folder", "%d folders", 2), "%d folders")
+		}
+	}
+}
+
+func TestFrPGettext(t *testing.T) {
+	file, err := os.Open("testdata/fr/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMO(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.PGettext("verb", "Open"), "Ouvrir")
+	assert_equal(t, catalog.PGettext("adjective", "Open"), "Ouvert")
+	assert_equal(t, catalog.PGettext("noun", "Open"), "Open")
+	assert_equal(t, catalog.Gettext("Open"), "Open")
+	assert_equal(t, catalog.Gettext("greeting"), "Bonjour")
+	assert_equal(t, catalog.PGettext("verb", "greeting"), "greeting")
+}
+
+func TestFrNPGettext(t *testing.T) {
+	file, err := os.Open("testdata/fr/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMO(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t,
+		fmt.Sprintf(catalog.NPGettext("mail", "%d new message", "%d new messages", 1), 1),
+		"1 nouveau message",
+	)
+	assert_equal(t,
+		fmt.Sprintf(catalog.NPGettext("mail", "%d new message", "%d new messages", 2), 2),
+		"2 nouveaux messages",
+	)
+	assert_equal(t,
+		fmt.Sprintf(catalog.NPGettext("chat", "%d new message", "%d new messages", 2), 2),
+		"2 new messages",
+	)
+	assert_equal(t,
+		fmt.Sprintf(catalog.NGettext("%d new message", "%d new messages", 1), 1),
+		"1 new message",
+	)
+}
+
+func TestDecompileMO(t *testing.T) {
+	for _, locale := range []string{"en", "ja"} {
+		data, err := os.ReadFile("testdata/" + locale + "/messages.mo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		po, err := DecompileMO(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		_, err = po.WriteTo(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := os.ReadFile("testdata/" + locale + "/messages.po")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_equal(t, out.String(), string(expected))
+	}
+	data, err := os.ReadFile("testdata/fr/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	po, err := DecompileMO(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, po.HeaderField("Language"), "fr")
+	for _, entry := range po.Entries {
+		if entry.ID == "%d new message" {
+			assert_equal(t, entry.Context, "mail")
+			assert_equal(t, entry.IDPlural, "%d new messages")
+			assert_equal(t, entry.Str[1], "%d nouveaux messages")
+		}
+	}
+	_, err = DecompileMO([]byte("not a mo file"))
+	if !errors.Is(err, ErrCorruptCatalog) {
+		t.Errorf("expected corrupt catalog error, got %v", err)
+	}
+}
+
+func TestCatalogMetadata(t *testing.T) {
+	data, err := os.ReadFile("testdata/en/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMOBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Language(), "en")
+	assert_equal(t, catalog.Charset(), "UTF-8")
+	assert_equal(t, catalog.Header("Content-Transfer-Encoding"), "8bit")
+	assert_equal(t, catalog.Header("mime-version"), "1.0")
+	assert_equal(t, catalog.Header("Last-Translator"), "")
+	plural_forms := catalog.PluralForms()
+	if plural_forms.NPlurals != 2 {
+		t.Errorf("expected nplurals=2, got %d", plural_forms.NPlurals)
+	}
+	assert_equal(t, plural_forms.Plural, "(n != 1)")
+	if plural_forms.Expression == nil || plural_forms.Expression.Eval(5) != 1 {
+		t.Errorf("unexpected plural expression %v", plural_forms.Expression)
+	}
+
+	data, err = os.ReadFile("testdata/en-no-plural-forms/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err = ParseMOBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if catalog.PluralForms() != (PluralForms{}) {
+		t.Errorf("expected no plural forms, got %+v", catalog.PluralForms())
+	}
+
+	null := nullcatalog{}
+	assert_equal(t, null.Language(), "")
+	assert_equal(t, null.Header("Language"), "")
+}
+
+func assert_lookup(t *testing.T, msgstr string, ok bool, expected string, expected_ok bool) {
+	t.Helper()
+	assert_equal(t, msgstr, expected)
+	if ok != expected_ok {
+		t.Errorf("%q: expected ok to be %v", msgstr, expected_ok)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	data, err := os.ReadFile("testdata/fr/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ParseMOBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgstr, ok := catalog.Lookup("greeting")
+	assert_lookup(t, msgstr, ok, "Bonjour", true)
+	msgstr, ok = catalog.Lookup("missing")
+	assert_lookup(t, msgstr, ok, "missing", false)
+	msgstr, ok = catalog.PLookup("verb", "Open")
+	assert_lookup(t, msgstr, ok, "Ouvrir", true)
+	msgstr, ok = catalog.PLookup("noun", "Open")
+	assert_lookup(t, msgstr, ok, "Open", false)
+	msgstr, ok = catalog.NLookup("%d new message", "%d new messages", 2)
+	assert_lookup(t, msgstr, ok, "%d new messages", false)
+	msgstr, ok = catalog.NPLookup("mail", "%d new message", "%d new messages", 2)
+	assert_lookup(t, msgstr, ok, "%d nouveaux messages", true)
+	msgstr, ok = catalog.NPLookup("chat", "%d new message", "%d new messages", 1)
+	assert_lookup(t, msgstr, ok, "%d new message", false)
+
+	msgstr, ok = nullcatalog{}.NLookup("one", "many", 2)
+	assert_lookup(t, msgstr, ok, "many", false)
+}
+
+func FuzzParseMO(f *testing.F) {
+	for _, locale := range []string{"en", "ja", "en-no-plural-forms", "fr", "en_GB", "sysdep"} {
+		data, err := os.ReadFile("testdata/" + locale + "/messages.mo")
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		DecompileMO(data)
+		for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+			catalog, err := parse(data)
+			if err != nil {
+				if !errors.Is(err, ErrCorruptCatalog) && !errors.Is(err, ErrPluralForms) && !errors.Is(err, ErrUnknownCharset) {
+					t.Errorf("unexpected kind of error %v", err)
+				}
+				continue
+			}
+			catalog.Gettext("greeting")
+			catalog.PGettext("verb", "Open")
+			catalog.Language()
+			catalog.Header("Plural-Forms")
+			po, err := DecompileMO(data)
+			if err != nil {
+				continue
+			}
+			for _, entry := range po.Entries {
+				for _, n := range []uint32{0, 1, 2, 5, 11, 101, 2000, 1<<32 - 1} {
+					catalog.NPGettext(entry.Context, entry.ID, entry.IDPlural, n)
+					catalog.NGettext(entry.ID, entry.IDPlural, n)
+				}
+			}
+		}
+	})
+}
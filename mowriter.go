@@ -0,0 +1,153 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MOOptions controls the layout of the mo files written by WriteMO.
+type MOOptions struct {
+	// ByteOrder of the file, binary.LittleEndian if nil.
+	ByteOrder binary.ByteOrder
+	// NoHashTable leaves out the hash table GNU gettext uses to speed up
+	// lookups, like msgfmt --no-hash does.
+	NoHashTable bool
+}
+
+type mo_message struct {
+	key string
+	str string
+}
+
+// WriteMO compiles po into a mo file, like msgfmt does. Fuzzy, untranslated
+// and obsolete entries are left out. With the default options the output is
+// identical to that of GNU msgfmt.
+func WriteMO(w io.Writer, po *POFile, options MOOptions) error {
+	messages := []mo_message{}
+	if po.Header != nil && len(po.Header.Str) != 0 {
+		messages = append(messages, mo_message{key: "", str: po.Header.Str[0]})
+	}
+	for _, entry := range po.Entries {
+		if entry.Obsolete || entry.HasFlag("fuzzy") || !entry.Translated() {
+			continue
+		}
+		message := mo_message{key: entry.Key(), str: strings.Join(entry.Str, "\x00")}
+		if entry.Plural() {
+			message.key += "\x00" + entry.IDPlural
+		}
+		messages = append(messages, message)
+	}
+	_, err := w.Write(encode_mo(messages, options))
+	return err
+}
+
+func encode_mo(messages []mo_message, options MOOptions) []byte {
+	order := options.ByteOrder
+	if order == nil {
+		order = binary.LittleEndian
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].key < messages[j].key
+	})
+	n := uint32(len(messages))
+	var hash_size uint32
+	if !options.NoHashTable {
+		hash_size = hash_table_size(n)
+	}
+	const header_size = 28
+	master_index := uint32(header_size)
+	translations_index := master_index + 8*n
+	hash_index := translations_index + 8*n
+	strings_index := hash_index + 4*hash_size
+
+	var table bytes.Buffer
+	var data bytes.Buffer
+	put := func(v uint32) {
+		binary.Write(&table, order, v)
+	}
+	put(le_magic)
+	put(0)
+	put(n)
+	put(master_index)
+	put(translations_index)
+	put(hash_size)
+	put(hash_index)
+	for _, message := range messages {
+		put(uint32(len(message.key)))
+		put(strings_index + uint32(data.Len()))
+		data.WriteString(message.key)
+		data.WriteByte(0)
+	}
+	for _, message := range messages {
+		put(uint32(len(message.str)))
+		put(strings_index + uint32(data.Len()))
+		data.WriteString(message.str)
+		data.WriteByte(0)
+	}
+	if hash_size != 0 {
+		hash_table := make([]uint32, hash_size)
+		for i, message := range messages {
+			msgid, _, _ := strings.Cut(message.key, "\x00")
+			hash := hash_string(msgid)
+			index := hash % hash_size
+			incr := 1 + hash%(hash_size-2)
+			for hash_table[index] != 0 {
+				if index >= hash_size-incr {
+					index -= hash_size - incr
+				} else {
+					index += incr
+				}
+			}
+			hash_table[index] = uint32(i) + 1
+		}
+		for _, v := range hash_table {
+			put(v)
+		}
+	}
+	table.Write(data.Bytes())
+	return table.Bytes()
+}
+
+// hash_string is the hash function used for the hash table of mo files.
+func hash_string(s string) uint32 {
+	var hash uint32
+	for i := 0; i < len(s); i++ {
+		hash = (hash << 4) + uint32(s[i])
+		g := hash & 0xf0000000
+		if g != 0 {
+			hash ^= g >> 24
+			hash ^= g
+		}
+	}
+	return hash
+}
+
+// hash_table_size returns the hash table size msgfmt uses for n strings.
+func hash_table_size(n uint32) uint32 {
+	size := next_prime(n * 4 / 3)
+	if size <= 2 {
+		size = 3
+	}
+	return size
+}
+
+func next_prime(n uint32) uint32 {
+	if n < 2 {
+		return 2
+	}
+	for ; ; n++ {
+		prime := true
+		for d := uint32(2); d*d <= n; d++ {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse_po_file(t *testing.T, path string) *POFile {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	po, err := ParsePO(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return po
+}
+
+func TestWriteMOMatchesMsgfmt(t *testing.T) {
+	// The en, ja and en-no-plural-forms mo files were compiled by GNU msgfmt.
+	// The fr and en_GB ones were not, they only catch changes of the output.
+	for locale, compiler := range map[string]string{
+		"en":                 "msgfmt",
+		"ja":                 "msgfmt",
+		"en-no-plural-forms": "msgfmt",
+		"fr":                 "the checked in mo file",
+		"en_GB":              "the checked in mo file",
+	} {
+		po := parse_po_file(t, "testdata/"+locale+"/messages.po")
+		var out bytes.Buffer
+		err := WriteMO(&out, po, MOOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := os.ReadFile("testdata/" + locale + "/messages.mo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), expected) {
+			t.Errorf("%s: mo file differs from %s", locale, compiler)
+		}
+	}
+}
+
+func TestWriteMORoundTrip(t *testing.T) {
+	po, err := ParsePO(strings.NewReader(full_po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := po.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, options := range []MOOptions{
+		{},
+		{ByteOrder: binary.BigEndian},
+		{NoHashTable: true},
+		{ByteOrder: binary.BigEndian, NoHashTable: true},
+	} {
+		var out bytes.Buffer
+		err := WriteMO(&out, po, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		catalog, err := ParseMOBytes(out.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(catalog.(mocatalog).messages, expected.(mocatalog).messages) {
+			t.Errorf("%+v: messages differ after round trip", options)
+		}
+		assert_equal(t, catalog.PGettext("verb", "Open"), "Öffnen")
+		assert_equal(t, catalog.NGettext("%d file", "%d files", 2), "%d Dateien")
+		assert_equal(t, catalog.Gettext("Good bye"), "Good bye")
+	}
+}
+
+func TestHashString(t *testing.T) {
+	// Reference values of GNU gettext's hash_string
+	for s, expected := range map[string]uint32{
+		"":                              0,
+		"greeting":                      0x08bcaaa7,
+		"order %d beer":                 0x0dec4f92,
+		"a long message that overflows": 0x0f825843,
+	} {
+		if got := hash_string(s); got != expected {
+			t.Errorf("%q: expected %#x, got %#x", s, expected, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package pluralforms
+
+// known_formulas are the Plural-Forms expressions most catalogs use, see
+// https://www.gnu.org/software/gettext/manual/html_node/Plural-forms.html.
+// Compile evaluates them with the hand written functions instead of the
+// generic closures.
+var known_formulas = []struct {
+	formula string
+	eval    func(n uint32) int
+}{
+	{"n != 1", plural_not_one},
+	{"n > 1", plural_above_one},
+	{"n%10==1 && n%100!=11 ? 0 : n != 0 ? 1 : 2", plural_latvian},
+	{"n==1 ? 0 : n==2 ? 1 : 2", plural_one_two},
+	{"n==1 ? 0 : n==2 ? 1 : (n>2 && n<7) ? 2 :(n>6 && n<11) ? 3 : 4", plural_irish},
+	{"n==1 ? 0 : (n==0 || (n%100 > 0 && n%100 < 20)) ? 1 : 2", plural_romanian},
+	{"n%10==1 && n%100!=11 ? 0 : n%10>=2 && (n%100<10 || n%100>=20) ? 1 : 2", plural_lithuanian},
+	{"n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2", plural_east_slavic},
+	{"(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2", plural_czech},
+	{"n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2", plural_polish},
+	{"n%100==1 ? 0 : n%100==2 ? 1 : n%100==3 || n%100==4 ? 2 : 3", plural_slovenian},
+	{"n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5", plural_arabic},
+	{"n%10!=1 || n%100==11", plural_icelandic},
+}
+
+// known maps the folded syntax trees of known_formulas to their functions.
+var known = map[Node]*func(n uint32) int{}
+
+func init() {
+	for i := range known_formulas {
+		root, err := parse(known_formulas[i].formula)
+		if err != nil {
+			panic(err)
+		}
+		known[root] = &known_formulas[i].eval
+	}
+}
+
+func plural_not_one(n uint32) int {
+	if n != 1 {
+		return 1
+	}
+	return 0
+}
+
+func plural_above_one(n uint32) int {
+	if n > 1 {
+		return 1
+	}
+	return 0
+}
+
+func plural_latvian(n uint32) int {
+	if n%10 == 1 && n%100 != 11 {
+		return 0
+	}
+	if n != 0 {
+		return 1
+	}
+	return 2
+}
+
+func plural_one_two(n uint32) int {
+	switch n {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	}
+	return 2
+}
+
+func plural_irish(n uint32) int {
+	switch {
+	case n == 1:
+		return 0
+	case n == 2:
+		return 1
+	case n > 2 && n < 7:
+		return 2
+	case n > 6 && n < 11:
+		return 3
+	}
+	return 4
+}
+
+func plural_romanian(n uint32) int {
+	if n == 1 {
+		return 0
+	}
+	if n == 0 || (n%100 > 0 && n%100 < 20) {
+		return 1
+	}
+	return 2
+}
+
+func plural_lithuanian(n uint32) int {
+	if n%10 == 1 && n%100 != 11 {
+		return 0
+	}
+	if n%10 >= 2 && (n%100 < 10 || n%100 >= 20) {
+		return 1
+	}
+	return 2
+}
+
+func plural_east_slavic(n uint32) int {
+	if n%10 == 1 && n%100 != 11 {
+		return 0
+	}
+	if n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20) {
+		return 1
+	}
+	return 2
+}
+
+func plural_czech(n uint32) int {
+	if n == 1 {
+		return 0
+	}
+	if n >= 2 && n <= 4 {
+		return 1
+	}
+	return 2
+}
+
+func plural_polish(n uint32) int {
+	if n == 1 {
+		return 0
+	}
+	if n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20) {
+		return 1
+	}
+	return 2
+}
+
+func plural_slovenian(n uint32) int {
+	switch n % 100 {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	case 3, 4:
+		return 2
+	}
+	return 3
+}
+
+func plural_arabic(n uint32) int {
+	switch {
+	case n == 0:
+		return 0
+	case n == 1:
+		return 1
+	case n == 2:
+		return 2
+	case n%100 >= 3 && n%100 <= 10:
+		return 3
+	case n%100 >= 11:
+		return 4
+	}
+	return 5
+}
+
+func plural_icelandic(n uint32) int {
+	if n%10 != 1 || n%100 == 11 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package pluralforms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKnownFormulas(t *testing.T) {
+	for _, f := range known_formulas {
+		expr, err := Compile(f.formula)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := expr.(expression)
+		if reflect.ValueOf(*e.eval).Pointer() != reflect.ValueOf(f.eval).Pointer() {
+			t.Errorf("'%s' does not use its hand written function", f.formula)
+		}
+		generic := lower(e.root)
+		for n := uint32(0); n <= 1000; n++ {
+			if got, want := f.eval(n), generic(n); got != want {
+				t.Errorf("'%s' with n = %d, expected %d, got %d", f.formula, n, want, got)
+			}
+		}
+		for _, n := range []uint32{1000001, 1000011, 1 << 31, 1<<32 - 1} {
+			if got, want := f.eval(n), generic(n); got != want {
+				t.Errorf("'%s' with n = %d, expected %d, got %d", f.formula, n, want, got)
+			}
+		}
+	}
+}
+
+func TestKnownFormulaSpelling(t *testing.T) {
+	// Spacing and redundant parentheses do not matter
+	for _, s := range []string{"n!=1", "(n != 1)", "((n)!=(1))"} {
+		expr, err := Compile(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eval := *expr.(expression).eval
+		if reflect.ValueOf(eval).Pointer() != reflect.ValueOf(plural_not_one).Pointer() {
+			t.Errorf("'%s' does not use plural_not_one", s)
+		}
+	}
+}
@@ -3,425 +3,216 @@ package pluralforms
 import (
 	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
-	"strings"
 )
 
-type match struct {
-	open_pos  int
-	close_pos int
-}
-
-var pat = regexp.MustCompile(`(\?|:|\|\||&&|==|!=|>=|>|<=|<|%|\d+|n)`)
-
-type expr_token interface {
-	compile(tokens []string) (expr Expression, err error)
-}
-
-type test_token interface {
-	compile(tokens []string) (test test, err error)
-}
-
-type cmp_test_builder func(val uint32, flipped bool) test
-type logic_test_build func(left test, right test) test
-
-var ternary_token ternary_
+// max_depth limits how deeply expressions may nest, so that hostile headers
+// cannot exhaust the stack.
+const max_depth = 1000
 
-type ternary_ struct{}
+// Operator precedences, from GNU gettext's plural.y. Higher binds tighter.
+const (
+	ternary_precedence = 1
+	unary_precedence   = 8
+)
 
-func (ternary_) compile(tokens []string) (expr Expression, err error) {
-	main, err := split_tokens(tokens, "?")
-	if err != nil {
-		return expr, err
-	}
-	test, err := compile_test(strings.Join(main.Left, ""))
-	if err != nil {
-		return expr, err
-	}
-	actions, err := split_tokens(main.Right, ":")
-	if err != nil {
-		return expr, err
-	}
-	true_action, err := compile_expression(strings.Join(actions.Left, ""))
-	if err != nil {
-		return expr, err
-	}
-	false_action, err := compile_expression(strings.Join(actions.Right, ""))
-	if err != nil {
-		return expr, nil
+var binary_precedence = map[string]int{
+	"||": 2,
+	"&&": 3,
+	"==": 4, "!=": 4,
+	"<": 5, "<=": 5, ">": 5, ">=": 5,
+	"+": 6, "-": 6,
+	"*": 7, "/": 7, "%": 7,
+}
+
+type token struct {
+	text  string // empty at the end of the expression
+	value uint64
+	pos   int
+}
+
+func (t token) String() string {
+	if t.text == "" {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// tokenize splits a plural forms expression into numbers, n, operators and
+// parentheses.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9':
+			start := i
+			var value uint64
+			for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+				// wraps around like GNU gettext's unsigned long
+				value = value*10 + uint64(s[i]-'0')
+			}
+			tokens = append(tokens, token{text: s[start:i], value: value, pos: start})
+		case i+1 < len(s) && is_two_char_op(s[i:i+2]):
+			tokens = append(tokens, token{text: s[i : i+2], pos: i})
+			i += 2
+		case c == 'n' || c == '?' || c == ':' || c == '!' || c == '(' || c == ')':
+			tokens = append(tokens, token{text: s[i : i+1], pos: i})
+			i++
+		case binary_precedence[s[i:i+1]] != 0:
+			tokens = append(tokens, token{text: s[i : i+1], pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+		}
 	}
-	return ternary{
-		test:       test,
-		true_expr:  true_action,
-		false_expr: false_action,
-	}, nil
+	return append(tokens, token{pos: len(s)}), nil
 }
 
-var const_token const_val_
-
-type const_val_ struct{}
-
-func (const_val_) compile(tokens []string) (expr Expression, err error) {
-	if len(tokens) == 0 {
-		return expr, errors.New("Got nothing instead of constant")
+func is_two_char_op(s string) bool {
+	switch s {
+	case "||", "&&", "==", "!=", "<=", ">=":
+		return true
 	}
-	if len(tokens) != 1 {
-		return expr, fmt.Errorf("Invalid constant: %s", strings.Join(tokens, ""))
-	}
-	i, err := strconv.Atoi(tokens[0])
-	if err != nil {
-		return expr, err
-	}
-	return const_value{value: i}, nil
+	return false
 }
 
-func compile_logic_test(tokens []string, sep string, builder logic_test_build) (test test, err error) {
-	split, err := split_tokens(tokens, sep)
-	if err != nil {
-		return test, err
-	}
-	left, err := compile_test(strings.Join(split.Left, ""))
-	if err != nil {
-		return test, err
-	}
-	right, err := compile_test(strings.Join(split.Right, ""))
-	if err != nil {
-		return test, err
-	}
-	return builder(left, right), nil
+func is_number(t token) bool {
+	return t.text != "" && t.text[0] >= '0' && t.text[0] <= '9'
 }
 
-var or_token or_
-
-type or_ struct{}
-
-func (or_) compile(tokens []string) (test test, err error) {
-	return compile_logic_test(tokens, "||", build_or)
-}
-func build_or(left test, right test) test {
-	return or{left: left, right: right}
+// parser is a precedence climbing parser for the C subset GNU gettext
+// accepts in Plural-Forms headers.
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
 }
 
-var and_token and_
-
-type and_ struct{}
-
-func (and_) compile(tokens []string) (test test, err error) {
-	return compile_logic_test(tokens, "&&", build_and)
-}
-func build_and(left test, right test) test {
-	return and{left: left, right: right}
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
 }
 
-func compile_mod(tokens []string) (math math, err error) {
-	split, err := split_tokens(tokens, "%")
-	if err != nil {
-		return math, err
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.text != "" {
+		p.pos++
 	}
-	if len(split.Left) != 1 || split.Left[0] != "n" {
-		return math, errors.New("Modulus operation requires 'n' as left operand")
-	}
-	if len(split.Right) != 1 {
-		return math, errors.New("Modulus operation requires simple integer as right operand")
-	}
-	i, err := parse_uint32(split.Right[0])
-	if err != nil {
-		return math, err
-	}
-	return mod{value: uint32(i)}, nil
+	return t
 }
 
-func _pipe(mod_tokens []string, action_tokens []string, builder cmp_test_builder, flipped bool) (test test, err error) {
-	modifier, err := compile_mod(mod_tokens)
-	if err != nil {
-		return test, err
-	}
-	if len(action_tokens) != 1 {
-		return test, errors.New("Can only get modulus of integer")
-	}
-	i, err := parse_uint32(action_tokens[0])
-	if err != nil {
-		return test, err
+func (p *parser) expect(text string) error {
+	if t := p.next(); t.text != text {
+		return fmt.Errorf("expected %q, got %s at offset %d", text, t, t.pos)
 	}
-	action := builder(uint32(i), flipped)
-	return pipe{
-		modifier: modifier,
-		action:   action,
-	}, nil
+	return nil
 }
 
-func compile_equality(tokens []string, sep string, builder cmp_test_builder) (test test, err error) {
-	split, err := split_tokens(tokens, sep)
-	if err != nil {
-		return test, err
+// expression parses operators binding at least as tightly as min.
+func (p *parser) expression(min int) (Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > max_depth {
+		return nil, errors.New("expression nested too deeply")
 	}
-	if len(split.Left) == 1 && split.Left[0] == "n" {
-		if len(split.Right) != 1 {
-			return test, errors.New("test can only compare n to integers")
-		}
-		i, err := parse_uint32(split.Right[0])
-		if err != nil {
-			return test, err
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op.text == "?" && min <= ternary_precedence {
+			p.next()
+			true_expr, err := p.expression(ternary_precedence)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			false_expr, err := p.expression(ternary_precedence)
+			if err != nil {
+				return nil, err
+			}
+			left = Conditional{Test: left, Then: true_expr, Else: false_expr}
+			continue
 		}
-		return builder(i, false), nil
-	} else if len(split.Right) == 1 && split.Right[0] == "n" {
-		if len(split.Left) != 1 {
-			return test, errors.New("test can only compare n to integers")
+		precedence := binary_precedence[op.text]
+		if precedence == 0 || precedence < min {
+			return left, nil
 		}
-		i, err := parse_uint32(split.Left[0])
+		p.next()
+		right, err := p.expression(precedence + 1)
 		if err != nil {
-			return test, err
+			return nil, err
 		}
-		return builder(i, true), nil
-	} else if contains(split.Left, "n") && contains(split.Left, "%") {
-		return _pipe(split.Left, split.Right, builder, false)
-	} else {
-		return test, errors.New("equality test must have 'n' as one of the two tests")
-	}
-}
-
-var eq_token eq_
-
-type eq_ struct{}
-
-func (eq_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "==", build_eq)
-}
-func build_eq(val uint32, flipped bool) test {
-	return equal{value: val}
-}
-
-var neq_token neq_
-
-type neq_ struct{}
-
-func (neq_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "!=", build_neq)
-}
-func build_neq(val uint32, flipped bool) test {
-	return notequal{value: val}
-}
-
-var gt_token gt_
-
-type gt_ struct{}
-
-func (gt_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, ">", build_gt)
-}
-func build_gt(val uint32, flipped bool) test {
-	return gt{value: val, flipped: flipped}
-}
-
-var gte_token gte_
-
-type gte_ struct{}
-
-func (gte_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, ">=", build_gte)
-}
-func build_gte(val uint32, flipped bool) test {
-	return gte{value: val, flipped: flipped}
-}
-
-var lt_token lt_
-
-type lt_ struct{}
-
-func (lt_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "<", build_lt)
-}
-func build_lt(val uint32, flipped bool) test {
-	return lt{value: val, flipped: flipped}
-}
-
-var lte_token lte_
-
-type lte_ struct{}
-
-func (lte_) compile(tokens []string) (test test, err error) {
-	return compile_equality(tokens, "<=", build_lte)
-}
-func build_lte(val uint32, flipped bool) test {
-	return lte{value: val, flipped: flipped}
-}
-
-type test_token_def struct {
-	op    string
-	token test_token
-}
-
-var precedence = []test_token_def{
-	test_token_def{op: "||", token: or_token},
-	test_token_def{op: "&&", token: and_token},
-	test_token_def{op: "==", token: eq_token},
-	test_token_def{op: "!=", token: neq_token},
-	test_token_def{op: ">=", token: gte_token},
-	test_token_def{op: ">", token: gt_token},
-	test_token_def{op: "<=", token: lte_token},
-	test_token_def{op: "<", token: lt_token},
-}
-
-type splitted struct {
-	Left  []string
-	Right []string
-}
-
-// Find index of token in list of tokens
-func index(tokens []string, sep string) int {
-	for index, token := range tokens {
-		if token == sep {
-			return index
+		if c, ok := right.(Number); ok && c.Value == 0 && (op.text == "/" || op.text == "%") {
+			return nil, fmt.Errorf("division by zero at offset %d", op.pos)
 		}
+		left = Binary{Op: op.text, Left: left, Right: right}
 	}
-	return -1
-}
-
-// Split a list of tokens by a token into a splitted struct holding the tokens
-// before and after the token to be split by.
-func split_tokens(tokens []string, sep string) (s splitted, err error) {
-	index := index(tokens, sep)
-	if index == -1 {
-		return s, fmt.Errorf("'%s' not found in ['%s']", sep, strings.Join(tokens, "','"))
-	}
-	return splitted{
-		Left:  tokens[:index],
-		Right: tokens[index+1:],
-	}, nil
 }
 
-// Scan a string for parenthesis
-func scan(s string) <-chan match {
-	ch := make(chan match)
-	go func() {
-		depth := 0
-		opener := 0
-		for index, char := range s {
-			switch char {
-			case '(':
-				if depth == 0 {
-					opener = index
-				}
-				depth++
-			case ')':
-				depth--
-				if depth == 0 {
-					ch <- match{
-						open_pos: opener,
-						close_pos: index + 1,
-					}
-				}
-			}
-
-		}
-		close(ch)
-	}()
-	return ch
-}
-
-// Split the string into tokens
-func split(s string) <- chan string {
-	ch := make(chan string)
-	go func() {
-		s = strings.Replace(s, " ", "", -1)
-		if !strings.Contains(s, "(") {
-			ch <- s
-		} else {
-			last := 0
-			end := len(s)
-			for info := range scan(s) {
-				if last != info.open_pos {
-					ch <- s[last:info.open_pos]
-				}
-				ch <- s[info.open_pos:info.close_pos]
-				last = info.close_pos
-			}
-			if last != end {
-				ch <- s[last:]
-			}
+func (p *parser) unary() (Node, error) {
+	t := p.next()
+	switch {
+	case t.text == "!":
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
 		}
-		close(ch)
-	}()
-	return ch
-}
-
-// Tokenizes a string into a list of strings, tokens grouped by parenthesis are
-// not split! If the string starts with ( and ends in ), those are stripped.
-func tokenize(s string) []string {
-	/*
-		TODO: Properly detect if the string starts with a ( and ends with a )
-		and that those two form a matching pair.
-
-		Eg: (foo) -> true; (foo)(bar) -> false;
-	*/
-	if s[0] == '(' && s[len(s)-1] == ')' {
-		s = s[1 : len(s)-1]
-	}
-	ret := []string{}
-	for chunk := range split(s) {
-		if len(chunk) != 0 {
-			if chunk[0] == '(' && chunk[len(chunk)-1] == ')' {
-				ret = append(ret, chunk)
-			} else {
-				for _, token := range pat.FindAllStringSubmatch(chunk, -1) {
-					ret = append(ret, token[0])
-				}
-			}
-		} else {
-			fmt.Printf("Empty chunk in string '%s'\n", s)
+		return Not{Operand: operand}, nil
+	case t.text == "(":
+		expr, err := p.expression(ternary_precedence)
+		if err != nil {
+			return nil, err
 		}
+		return expr, p.expect(")")
+	case t.text == "n":
+		return Variable{}, nil
+	case is_number(t):
+		return Number{Value: t.value}, nil
 	}
-	return ret
+	return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
 }
 
 // Compile a string containing a plural form expression to a Expression object.
+// It accepts the grammar of GNU gettext: n, decimal numbers, parentheses,
+// !, * / %, + -, < <= > >=, == !=, &&, || and ?: with C's precedence.
 func Compile(s string) (expr Expression, err error) {
-	if s == "0" {
-		return const_value{value: 0}, nil
+	root, err := parse(s)
+	if err != nil {
+		return nil, err
 	}
-	if !strings.Contains(s, "?") {
-		s += "?1:0"
+	if eval, ok := known[root]; ok {
+		return expression{root: root, eval: eval}, nil
 	}
-	return compile_expression(s)
+	eval := lower(root)
+	return expression{root: root, eval: &eval}, nil
 }
 
-// Check if a token is in a slice of strings
-func contains(haystack []string, needle string) bool {
-	for _, s := range haystack {
-		if s == needle {
-			return true
-		}
-	}
-	return false
-}
-
-// Compiles an expression (ternary or constant)
-func compile_expression(s string) (expr Expression, err error) {
-	tokens := tokenize(s)
-	if contains(tokens, "?") {
-		return ternary_token.compile(tokens)
-	} else {
-		return const_token.compile(tokens)
+// parse returns the syntax tree of an expression, with constants folded.
+func parse(s string) (Node, error) {
+	root, err := parse_tree(s)
+	if err != nil {
+		return nil, err
 	}
+	return fold(root)
 }
 
-// Compiles a test (comparison)
-func compile_test(s string) (test test, err error) {
-	tokens := tokenize(s)
-	for _, token_def := range precedence {
-		if contains(tokens, token_def.op) {
-			return token_def.token.compile(tokens)
-		}
+func parse_tree(s string) (Node, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
 	}
-	return test, errors.New("Cannot compile")
-}
-
-func parse_uint32(s string) (ui uint32, err error) {
-	i, err := strconv.ParseUint(s, 10, 32)
+	p := &parser{tokens: tokens}
+	root, err := p.expression(ternary_precedence)
 	if err != nil {
-		return ui, err
-	} else {
-		return uint32(i), nil
+		return nil, err
+	}
+	if t := p.peek(); t.text != "" {
+		return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
 	}
+	return root, nil
 }
@@ -3,6 +3,7 @@ package pluralforms
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -43,3 +44,100 @@ func TestCompiler(t *testing.T) {
 		}
 	}
 }
+
+func TestCompileUnsigned(t *testing.T) {
+	tests := []struct {
+		expr string
+		n    uint32
+		want int
+	}{
+		{"n-1>n", 0, 1},
+		{"n-1>n", 1, 0},
+		{"(n-2)/2", 1, -1},
+		{"n/(n-1)", 1, -1},
+		{"n%(n-1)", 1, -1},
+		{"n==1||n/(n-1)", 1, 1},
+		{"n!=1&&n%(n-1)", 1, 0},
+		{"n==1?0:n/(n-1)", 1, 0},
+		{"18446744073709551617", 0, 1},
+		{"!!n", 5, 1},
+		{"2+3*4-n%3", 4, 13},
+		{"1?2:3?4:5", 0, 2},
+		{"0?2:0?4:5", 0, 5},
+		{"n<2==n<3", 2, 0},
+	}
+	for _, test := range tests {
+		expr, err := Compile(test.expr)
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+			continue
+		}
+		if got := expr.Eval(test.n); got != test.want {
+			t.Errorf("%s with n = %d: expected %d, got %d", test.expr, test.n, test.want, got)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                              "unexpected end of expression at offset 0",
+		"n==":                           "unexpected end of expression at offset 3",
+		"n=1":                           "unexpected character '=' at offset 1",
+		"(n":                            `expected ")", got end of expression at offset 2`,
+		"n?1":                           `expected ":", got end of expression at offset 3`,
+		"n 1":                           `unexpected "1" at offset 2`,
+		"n%0":                           "division by zero at offset 1",
+		"n/(0)":                         "division by zero at offset 1",
+		"-n":                            `unexpected "-" at offset 0`,
+		"x":                             "unexpected character 'x' at offset 0",
+		strings.Repeat("(", 2000) + "n": "expression nested too deeply",
+	}
+	for expr, want := range tests {
+		_, err := Compile(expr)
+		if err == nil {
+			t.Errorf("%q compiled", expr)
+			continue
+		}
+		if err.Error() != want {
+			t.Errorf("%q: expected error %q, got %q", expr, want, err)
+		}
+	}
+}
+
+func FuzzCompile(f *testing.F) {
+	data, err := os.ReadFile("testdata/plural_forms.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	var fixtures []fixture
+	err = json.Unmarshal(data, &fixtures)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, data := range fixtures {
+		f.Add(data.PluralForm)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		expr, err := Compile(s)
+		if err != nil {
+			return
+		}
+		for _, n := range []uint32{0, 1, 2, 5, 11, 21, 101, 1000000, 1<<32 - 1} {
+			expr.Eval(n)
+		}
+		again, err := Compile(expr.String())
+		if err != nil {
+			t.Fatalf("%q compiled to %s, which does not compile: %s", s, expr, err)
+		}
+		if !Equal(expr, again) {
+			t.Fatalf("%q compiled to %s, then to %s", s, expr, again)
+		}
+		root, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := Parse(root.String()); again != root {
+			t.Fatalf("%q parsed to %s, then to %s", s, root, again)
+		}
+	})
+}
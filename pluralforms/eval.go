@@ -0,0 +1,272 @@
+package pluralforms
+
+import (
+	"errors"
+	"math"
+)
+
+// value_func and test_func are compiled expressions, used as numbers and as
+// conditions respectively.
+type value_func func(n uint64) uint64
+type test_func func(n uint64) bool
+
+// division_by_zero is raised by compiled divisions and recovered by Eval.
+type division_by_zero struct{}
+
+// fold evaluates the constant parts of an expression.
+func fold(e Node) (Node, error) {
+	switch e := e.(type) {
+	case Not:
+		operand, err := fold(e.Operand)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := operand.(Number); ok {
+			return Number{Value: truth(c.Value == 0)}, nil
+		}
+		return Not{Operand: operand}, nil
+	case Binary:
+		left, err := fold(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		l, left_const := left.(Number)
+		if left_const && e.Op == "&&" && l.Value == 0 {
+			return Number{Value: 0}, nil
+		}
+		if left_const && e.Op == "||" && l.Value != 0 {
+			return Number{Value: 1}, nil
+		}
+		right, err := fold(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		r, right_const := right.(Number)
+		if right_const && r.Value == 0 && (e.Op == "/" || e.Op == "%") {
+			return nil, errors.New("division by zero")
+		}
+		folded := Binary{Op: e.Op, Left: left, Right: right}
+		if left_const && right_const {
+			v, _ := folded.eval(0)
+			return Number{Value: v}, nil
+		}
+		return folded, nil
+	case Conditional:
+		test, err := fold(e.Test)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := test.(Number); ok {
+			if c.Value != 0 {
+				return fold(e.Then)
+			}
+			return fold(e.Else)
+		}
+		true_expr, err := fold(e.Then)
+		if err != nil {
+			return nil, err
+		}
+		false_expr, err := fold(e.Else)
+		if err != nil {
+			return nil, err
+		}
+		return Conditional{Test: test, Then: true_expr, Else: false_expr}, nil
+	}
+	return e, nil
+}
+
+// lower compiles an expression to a tree of closures.
+func lower(root Node) func(n uint32) int {
+	if c, ok := root.(Number); ok {
+		i := to_int(c.Value)
+		return func(uint32) int { return i }
+	}
+	f := compile_value(root)
+	if !can_divide_by_zero(root) {
+		return func(n uint32) int {
+			return to_int(f(uint64(n)))
+		}
+	}
+	return func(n uint32) (i int) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(division_by_zero); !ok {
+					panic(r)
+				}
+				i = -1
+			}
+		}()
+		return to_int(f(uint64(n)))
+	}
+}
+
+func to_int(v uint64) int {
+	if v > math.MaxInt32 {
+		return -1
+	}
+	return int(v)
+}
+
+func can_divide_by_zero(e Node) bool {
+	switch e := e.(type) {
+	case Not:
+		return can_divide_by_zero(e.Operand)
+	case Binary:
+		if _, ok := e.Right.(Number); !ok && (e.Op == "/" || e.Op == "%") {
+			return true
+		}
+		return can_divide_by_zero(e.Left) || can_divide_by_zero(e.Right)
+	case Conditional:
+		return can_divide_by_zero(e.Test) || can_divide_by_zero(e.Then) || can_divide_by_zero(e.Else)
+	}
+	return false
+}
+
+func is_test(e Node) bool {
+	switch e := e.(type) {
+	case Not:
+		return true
+	case Binary:
+		switch e.Op {
+		case "&&", "||", "==", "!=", "<", "<=", ">", ">=":
+			return true
+		}
+	}
+	return false
+}
+
+func compile_value(e Node) value_func {
+	if is_test(e) {
+		test := compile_test(e)
+		return func(n uint64) uint64 {
+			if test(n) {
+				return 1
+			}
+			return 0
+		}
+	}
+	switch e := e.(type) {
+	case Number:
+		v := e.Value
+		return func(uint64) uint64 { return v }
+	case Variable:
+		return func(n uint64) uint64 { return n }
+	case Binary:
+		return compile_arithmetic(e)
+	case Conditional:
+		test := compile_test(e.Test)
+		true_expr := compile_value(e.Then)
+		false_expr := compile_value(e.Else)
+		return func(n uint64) uint64 {
+			if test(n) {
+				return true_expr(n)
+			}
+			return false_expr(n)
+		}
+	}
+	panic("pluralforms: cannot compile expression")
+}
+
+func compile_arithmetic(e Binary) value_func {
+	left := compile_value(e.Left)
+	if c, ok := e.Right.(Number); ok {
+		v := c.Value
+		if _, ok := e.Left.(Variable); ok && e.Op == "%" {
+			return func(n uint64) uint64 { return n % v }
+		}
+		switch e.Op {
+		case "+":
+			return func(n uint64) uint64 { return left(n) + v }
+		case "-":
+			return func(n uint64) uint64 { return left(n) - v }
+		case "*":
+			return func(n uint64) uint64 { return left(n) * v }
+		case "/":
+			return func(n uint64) uint64 { return left(n) / v }
+		case "%":
+			return func(n uint64) uint64 { return left(n) % v }
+		}
+	}
+	right := compile_value(e.Right)
+	switch e.Op {
+	case "+":
+		return func(n uint64) uint64 { return left(n) + right(n) }
+	case "-":
+		return func(n uint64) uint64 { return left(n) - right(n) }
+	case "*":
+		return func(n uint64) uint64 { return left(n) * right(n) }
+	case "/":
+		return func(n uint64) uint64 {
+			l, r := left(n), right(n)
+			if r == 0 {
+				panic(division_by_zero{})
+			}
+			return l / r
+		}
+	case "%":
+		return func(n uint64) uint64 {
+			l, r := left(n), right(n)
+			if r == 0 {
+				panic(division_by_zero{})
+			}
+			return l % r
+		}
+	}
+	panic("pluralforms: unknown operator " + e.Op)
+}
+
+func compile_test(e Node) test_func {
+	switch e := e.(type) {
+	case Not:
+		operand := compile_test(e.Operand)
+		return func(n uint64) bool { return !operand(n) }
+	case Binary:
+		switch e.Op {
+		case "&&":
+			left, right := compile_test(e.Left), compile_test(e.Right)
+			return func(n uint64) bool { return left(n) && right(n) }
+		case "||":
+			left, right := compile_test(e.Left), compile_test(e.Right)
+			return func(n uint64) bool { return left(n) || right(n) }
+		case "==", "!=", "<", "<=", ">", ">=":
+			return compile_comparison(e)
+		}
+	}
+	value := compile_value(e)
+	return func(n uint64) bool { return value(n) != 0 }
+}
+
+func compile_comparison(e Binary) test_func {
+	left := compile_value(e.Left)
+	if c, ok := e.Right.(Number); ok {
+		v := c.Value
+		switch e.Op {
+		case "==":
+			return func(n uint64) bool { return left(n) == v }
+		case "!=":
+			return func(n uint64) bool { return left(n) != v }
+		case "<":
+			return func(n uint64) bool { return left(n) < v }
+		case "<=":
+			return func(n uint64) bool { return left(n) <= v }
+		case ">":
+			return func(n uint64) bool { return left(n) > v }
+		case ">=":
+			return func(n uint64) bool { return left(n) >= v }
+		}
+	}
+	right := compile_value(e.Right)
+	switch e.Op {
+	case "==":
+		return func(n uint64) bool { return left(n) == right(n) }
+	case "!=":
+		return func(n uint64) bool { return left(n) != right(n) }
+	case "<":
+		return func(n uint64) bool { return left(n) < right(n) }
+	case "<=":
+		return func(n uint64) bool { return left(n) <= right(n) }
+	case ">":
+		return func(n uint64) bool { return left(n) > right(n) }
+	}
+	return func(n uint64) bool { return left(n) >= right(n) }
+}
@@ -0,0 +1,104 @@
+package pluralforms
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := map[string]Node{
+		"2*3+1":          Number{Value: 7},
+		"!(1<2)":         Number{Value: 0},
+		"0&&n/(n-1)":     Number{Value: 0},
+		"1||n/(n-1)":     Number{Value: 1},
+		"1?n:n/(n-1)":    Variable{},
+		"n%(5*2)":        Binary{Op: "%", Left: Variable{}, Right: Number{Value: 10}},
+		"n==1?0:2>1?1:2": Conditional{Test: Binary{Op: "==", Left: Variable{}, Right: Number{Value: 1}}, Then: Number{Value: 0}, Else: Number{Value: 1}},
+		"0-1":            Number{Value: 1<<64 - 1},
+		"(((n)))":        Variable{},
+		"n>1&&(2-2)":     Binary{Op: "&&", Left: Binary{Op: ">", Left: Variable{}, Right: Number{Value: 1}}, Right: Number{Value: 0}},
+	}
+	for s, want := range tests {
+		got, err := parse(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+		} else if got != want {
+			t.Errorf("%s: expected %#v, got %#v", s, want, got)
+		}
+	}
+	if _, err := parse("n/(1-1)"); err == nil || err.Error() != "division by zero" {
+		t.Errorf("expected division by zero, got %v", err)
+	}
+}
+
+func TestLower(t *testing.T) {
+	// Compares the closures to walking the tree
+	exprs := []string{
+		"n-1>n", "(n-2)/2", "n/(n-1)", "n%(n-1)", "n%(n%3)+1", "!n", "!(n%2)+n*3",
+		"n<n*2", "n<=n/2", "n>=n-1", "n==n%7", "n!=1+n", "n*n*n*n*n*n*n",
+		"n?n:0", "n%3?n/3:n-3",
+	}
+	for _, s := range exprs {
+		root, err := parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eval := lower(root)
+		for n := uint32(0); n <= 100; n++ {
+			want := walk(root)(n)
+			if got := eval(n); got != want {
+				t.Errorf("'%s' with n = %d, expected %d, got %d", s, n, want, got)
+			}
+		}
+	}
+}
+
+// walk evaluates expressions by walking the syntax tree.
+func walk(root Node) func(n uint32) int {
+	return func(n uint32) int {
+		v, ok := root.eval(uint64(n))
+		if !ok {
+			return -1
+		}
+		return to_int(v)
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	data, err := os.ReadFile("testdata/plural_forms.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var fixtures []fixture
+	err = json.Unmarshal(data, &fixtures)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, data := range fixtures {
+		root, err := parse(data.PluralForm)
+		if err != nil {
+			b.Fatal(err)
+		}
+		evals := []struct {
+			name string
+			eval func(n uint32) int
+		}{
+			{"tree", walk(root)},
+			{"closures", lower(root)},
+		}
+		if eval, ok := known[root]; ok {
+			evals = append(evals, struct {
+				name string
+				eval func(n uint32) int
+			}{"known", *eval})
+		}
+		for _, e := range evals {
+			b.Run(data.PluralForm+"/"+e.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					e.eval(uint32(i % 1000))
+				}
+			})
+		}
+	}
+}
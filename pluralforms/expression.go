@@ -1,39 +1,227 @@
 package pluralforms
 
+import "strconv"
+
 // Expression is a plurfalforms expression. Eval evaluates the expression for
-// a given n value. Use pluralforms.Compile to generate Expression instances.
+// a given n value. String returns the expression in canonical form, which
+// compiles to the same Expression again, and AST its syntax tree. Use
+// pluralforms.Compile to generate Expression instances.
 type Expression interface {
 	Eval(n uint32) int
+	String() string
+	AST() Node
+}
+
+// Node is the syntax tree of a plural forms expression, one of Number,
+// Variable, Not, Binary or Conditional. String prints it as a C expression
+// with as few parentheses as possible.
+//
+// Like GNU gettext, values are unsigned longs, so subtraction wraps around.
+// eval walks the tree, it is used for constant folding, Compile lowers nodes
+// to closures instead. It returns false if the expression divides by zero.
+type Node interface {
+	String() string
+	eval(n uint64) (uint64, bool)
+}
+
+// expression is a compiled Expression. eval is either one of the hand
+// written known_formulas or the closures lower built from root. It is a
+// pointer so expressions stay comparable: two compiled from the same known
+// formula are ==, Equal compares others.
+type expression struct {
+	root Node
+	eval *func(n uint32) int
+}
+
+// Eval returns -1 if the expression divides by zero or its value does not
+// fit an int.
+func (e expression) Eval(n uint32) int {
+	return (*e.eval)(n)
+}
+
+func (e expression) String() string {
+	return e.root.String()
+}
+
+func (e expression) AST() Node {
+	return e.root
+}
+
+// Equal reports whether a and b have the same canonical form.
+func Equal(a, b Expression) bool {
+	return a.AST() == b.AST()
+}
+
+// Equivalent reports whether a and b evaluate to the same plural form for
+// every n up to and including max. If they do not, it also returns the first
+// n they disagree on.
+func Equivalent(a, b Expression, max uint32) (bool, uint32) {
+	if Equal(a, b) {
+		return true, 0
+	}
+	for n := uint32(0); ; n++ {
+		if a.Eval(n) != b.Eval(n) {
+			return false, n
+		}
+		if n == max {
+			return true, 0
+		}
+	}
+}
+
+// Parse returns the syntax tree of a plural forms expression as written,
+// unlike Compile it does not evaluate constant subexpressions.
+func Parse(s string) (Node, error) {
+	return parse_tree(s)
+}
+
+// Number is a decimal constant.
+type Number struct {
+	Value uint64
+}
+
+func (c Number) String() string {
+	return strconv.FormatUint(c.Value, 10)
+}
+
+func (c Number) eval(n uint64) (uint64, bool) {
+	return c.Value, true
 }
 
-type const_value struct {
-	value int
+// Variable is n.
+type Variable struct{}
+
+func (Variable) String() string {
+	return "n"
+}
+
+func (Variable) eval(n uint64) (uint64, bool) {
+	return n, true
+}
+
+// Not is the logical negation !Operand.
+type Not struct {
+	Operand Node
+}
+
+func (e Not) String() string {
+	return "!" + parenthesize(e.Operand, unary_precedence)
 }
 
-func (c const_value) Eval(n uint32) int {
-	return c.value
+func (e Not) eval(n uint64) (uint64, bool) {
+	v, ok := e.Operand.eval(n)
+	return truth(v == 0), ok
 }
 
-type test interface {
-	test(n uint32) bool
+// Binary is Left Op Right, Op is one of * / % + - < <= > >= == != && ||.
+type Binary struct {
+	Op    string
+	Left  Node
+	Right Node
 }
 
-type ternary struct {
-	test       test
-	true_expr  Expression
-	false_expr Expression
+// String parenthesizes the right operand at the same precedence, as the
+// operators are left associative.
+func (e Binary) String() string {
+	precedence := binary_precedence[e.Op]
+	return parenthesize(e.Left, precedence) + " " + e.Op + " " + parenthesize(e.Right, precedence+1)
 }
 
-func (t ternary) Eval(n uint32) int {
-	if t.test.test(n) {
-		if t.true_expr == nil {
-			return -1
+func (e Binary) eval(n uint64) (uint64, bool) {
+	left, ok := e.Left.eval(n)
+	if !ok {
+		return 0, false
+	}
+	// && and || only evaluate their right operand if they need it
+	switch e.Op {
+	case "&&":
+		if left == 0 {
+			return 0, true
+		}
+	case "||":
+		if left != 0 {
+			return 1, true
 		}
-		return t.true_expr.Eval(n)
-	} else {
-		if t.false_expr == nil {
-			return -1
+	}
+	right, ok := e.Right.eval(n)
+	if !ok {
+		return 0, false
+	}
+	switch e.Op {
+	case "&&", "||":
+		return truth(right != 0), true
+	case "==":
+		return truth(left == right), true
+	case "!=":
+		return truth(left != right), true
+	case "<":
+		return truth(left < right), true
+	case "<=":
+		return truth(left <= right), true
+	case ">":
+		return truth(left > right), true
+	case ">=":
+		return truth(left >= right), true
+	case "+":
+		return left + right, true
+	case "-":
+		return left - right, true
+	case "*":
+		return left * right, true
+	case "/":
+		if right == 0 {
+			return 0, false
 		}
-		return t.false_expr.Eval(n)
+		return left / right, true
+	case "%":
+		if right == 0 {
+			return 0, false
+		}
+		return left % right, true
+	}
+	return 0, false
+}
+
+// Conditional is Test ? Then : Else.
+type Conditional struct {
+	Test Node
+	Then Node
+	Else Node
+}
+
+func (e Conditional) String() string {
+	return parenthesize(e.Test, ternary_precedence+1) + " ? " + e.Then.String() + " : " + e.Else.String()
+}
+
+func (e Conditional) eval(n uint64) (uint64, bool) {
+	v, ok := e.Test.eval(n)
+	if !ok {
+		return 0, false
+	}
+	if v != 0 {
+		return e.Then.eval(n)
+	}
+	return e.Else.eval(n)
+}
+
+func truth(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parenthesize prints e, in parentheses if it binds less tightly than min.
+func parenthesize(e Node, min int) string {
+	precedence := unary_precedence
+	switch e := e.(type) {
+	case Binary:
+		precedence = binary_precedence[e.Op]
+	case Conditional:
+		precedence = ternary_precedence
+	}
+	if precedence < min {
+		return "(" + e.String() + ")"
 	}
+	return e.String()
 }
@@ -0,0 +1,127 @@
+package pluralforms
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tests := map[string]string{
+		"0":                                      "0",
+		"n!=1":                                   "n != 1",
+		"(n==1)?0:(n>=2&&n<=4)?1:2":              "n == 1 ? 0 : n >= 2 && n <= 4 ? 1 : 2",
+		"n==1?0:(n==0||(n%100>0&&n%100<20))?1:2": "n == 1 ? 0 : n == 0 || n % 100 > 0 && n % 100 < 20 ? 1 : 2",
+		"(n%10+n/10)%3":                          "(n % 10 + n / 10) % 3",
+		"n-(n-1)":                                "n - (n - 1)",
+		"(n-n)-1":                                "n - n - 1",
+		"n/(n*2)":                                "n / (n * 2)",
+		"(n||n)&&n":                              "(n || n) && n",
+		"n||(n&&n)":                              "n || n && n",
+		"(n==1)==0":                              "n == 1 == 0",
+		"n==(1==0)":                              "n == 0",
+		"!(n==1)":                                "!(n == 1)",
+		"!!n":                                    "!!n",
+		"(n?1:2)?3:4":                            "(n ? 1 : 2) ? 3 : 4",
+		"n?(n?1:2):(n?3:4)":                      "n ? n ? 1 : 2 : n ? 3 : 4",
+		"(n?1:2)+1":                              "(n ? 1 : 2) + 1",
+		"n>1?1+2:3*4":                            "n > 1 ? 3 : 12",
+	}
+	for s, want := range tests {
+		expr, err := Compile(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if got := expr.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", s, want, got)
+		}
+		again, err := Compile(expr.String())
+		if err != nil {
+			t.Errorf("%s: %s", expr, err)
+		} else if !Equal(expr, again) {
+			t.Errorf("%s: compiled to %s, then %s", s, expr, again)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	root, err := Parse("n % (5 * 2) == 1 ? 0 : !n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Conditional{
+		Test: Binary{Op: "==", Left: Binary{Op: "%", Left: Variable{}, Right: Binary{Op: "*", Left: Number{Value: 5}, Right: Number{Value: 2}}}, Right: Number{Value: 1}},
+		Then: Number{Value: 0},
+		Else: Not{Operand: Variable{}},
+	}
+	if root != want {
+		t.Errorf("expected %#v, got %#v", want, root)
+	}
+	if got := root.String(); got != "n % (5 * 2) == 1 ? 0 : !n" {
+		t.Errorf("unexpected string %q", got)
+	}
+	expr, err := Compile(root.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := expr.AST().String(); got != "n % 10 == 1 ? 0 : !n" {
+		t.Errorf("unexpected compiled string %q", got)
+	}
+}
+
+func TestComparable(t *testing.T) {
+	for _, test := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"n != 1", "(n != 1)", true},
+		{"n != 1", "n > 1", false},
+		{"n % 3", "n % 3", false},
+	} {
+		a, err := Compile(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Compile(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a == b; got != test.equal {
+			t.Errorf("%s == %s: expected %v, got %v", test.a, test.b, test.equal, got)
+		}
+		if a != a {
+			t.Errorf("%s != itself", test.a)
+		}
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	compile := func(s string) Expression {
+		expr, err := Compile(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return expr
+	}
+	tests := []struct {
+		a, b   string
+		max    uint32
+		equal  bool
+		same   bool
+		differ uint32
+	}{
+		{"n != 1", "(n!=1)", 1000, true, true, 0},
+		{"n != 1", "!(n == 1)", 1000, false, true, 0},
+		{"n > 1", "n >= 2", 1000000, false, true, 0},
+		{"n != 1", "n > 1", 1000, false, false, 0},
+		{"n%10==1 ? 0 : 1", "n==1 ? 0 : 1", 10, false, true, 0},
+		{"n%10==1 ? 0 : 1", "n==1 ? 0 : 1", 11, false, false, 11},
+	}
+	for _, test := range tests {
+		a, b := compile(test.a), compile(test.b)
+		if got := Equal(a, b); got != test.equal {
+			t.Errorf("Equal(%s, %s) = %v", a, b, got)
+		}
+		same, n := Equivalent(a, b, test.max)
+		if same != test.same || n != test.differ {
+			t.Errorf("Equivalent(%s, %s, %d) = %v, %d", a, b, test.max, same, n)
+		}
+	}
+}
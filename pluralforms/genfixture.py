@@ -15,6 +15,15 @@ PLURAL_FORMS = [
     "n==1?0:n%10>=2&&n%10<=4&&(n%100<10||n%100>=20)?1:2",
     "n%100==1?0:n%100==2?1:n%100==3||n%100==4?2:3",
     "n==0?0:n==1?1:n==2?2:n%100>=3&&n%100<=10?3:n%100>=11?4:5",
+    "n != 1",
+    "!(n==1)",
+    "!n?2:n%2",
+    "(n%10+n/10)%3",
+    "(n+1)*(n+2)/2%4",
+    "n*2>=10?(n-5)/2%4:n%3",
+    "n==1?0:(n==2?1:n<7?2:n<11?3:4)",
+    "n>1?(n%100>10?1:2):0",
+    "n%10==1&&n%100!=11?0:(n%10>=2&&n%10<=4)&&!(n%100>=12&&n%100<=14)?1:2",
 ]
 
 NUM = 1000
@@ -28,7 +37,7 @@ def gen():
             'pluralform': plural_form,
             'fixture': [expr(n) for n in range(NUM + 1)]
         })
-    return json.dumps(tests)
+    return json.dumps(tests, sort_keys=True)
 
 
 if __name__ == "__main__":
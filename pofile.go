@@ -0,0 +1,576 @@
+package gettext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// POFile is the parsed, editable contents of a po (or pot) file.
+type POFile struct {
+	// Header is the entry with the empty msgid holding the meta data, nil if
+	// the file has none.
+	Header  *POEntry
+	Entries []*POEntry
+}
+
+// POEntry is a single message of a po file, together with its comments.
+type POEntry struct {
+	TranslatorComments []string // "# " lines
+	ExtractedComments  []string // "#. " lines
+	References         []string // "#: " file:line references
+	Flags              []string // "#, " flags such as fuzzy or c-format
+	PreviousContext    string   // "#| msgctxt" of fuzzy entries
+	PreviousID         string   // "#| msgid" of fuzzy entries
+	PreviousIDPlural   string   // "#| msgid_plural" of fuzzy entries
+	HasContext         bool
+	Context            string
+	ID                 string
+	IDPlural           string
+	// Str holds the msgstr, or msgstr[0] to msgstr[n] for plural entries.
+	Str      []string
+	Obsolete bool
+}
+
+// SyntaxError reports the line a po file could not be parsed at. It matches
+// ErrCorruptCatalog when used with errors.Is.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("po:%d: %s", e.Line, e.Msg)
+}
+
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrCorruptCatalog
+}
+
+// Plural reports whether the entry has plural forms.
+func (entry *POEntry) Plural() bool {
+	return entry.IDPlural != ""
+}
+
+// Key returns the key of the entry in a Catalog: the msgid, prefixed by the
+// context if there is one.
+func (entry *POEntry) Key() string {
+	if entry.HasContext {
+		return context_key(entry.Context, entry.ID)
+	}
+	return entry.ID
+}
+
+// Translated reports whether the entry has a translation. Like msgfmt, only
+// the first form is checked for plural entries.
+func (entry *POEntry) Translated() bool {
+	return len(entry.Str) != 0 && entry.Str[0] != ""
+}
+
+// HasFlag reports whether the entry is marked with flag, for example "fuzzy".
+func (entry *POEntry) HasFlag(flag string) bool {
+	return contains(entry.Flags, flag)
+}
+
+// AddFlag marks the entry with flag unless it already is.
+func (entry *POEntry) AddFlag(flag string) {
+	if !entry.HasFlag(flag) {
+		entry.Flags = append(entry.Flags, flag)
+	}
+}
+
+// RemoveFlag removes flag from the entry.
+func (entry *POEntry) RemoveFlag(flag string) {
+	flags := entry.Flags[:0]
+	for _, f := range entry.Flags {
+		if f != flag {
+			flags = append(flags, f)
+		}
+	}
+	entry.Flags = flags
+}
+
+// HeaderField returns the value of a field of the header entry, such as
+// "Language" or "Plural-Forms". Keys are case insensitive.
+func (po *POFile) HeaderField(key string) string {
+	if po.Header == nil || len(po.Header.Str) == 0 {
+		return ""
+	}
+	for _, line := range strings.Split(po.Header.Str[0], "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// SetHeaderField sets a field of the header entry, replacing the existing one
+// or adding it at the end. A header entry is created if there is none.
+func (po *POFile) SetHeaderField(key string, value string) {
+	if po.Header == nil {
+		po.Header = &POEntry{}
+	}
+	if len(po.Header.Str) == 0 {
+		po.Header.Str = []string{""}
+	}
+	lines := strings.SplitAfter(po.Header.Str[0], "\n")
+	field := key + ": " + value + "\n"
+	for i, line := range lines {
+		k, _, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			lines[i] = field
+			po.Header.Str[0] = strings.Join(lines, "")
+			return
+		}
+	}
+	header := po.Header.Str[0]
+	if header != "" && !strings.HasSuffix(header, "\n") {
+		header += "\n"
+	}
+	po.Header.Str[0] = header + field
+}
+
+// Catalog builds a Catalog from the translated entries. Like msgfmt, fuzzy
+// and obsolete entries are left out. Plural entries that do not have as many
+// translations as the header's nplurals are reported with ErrPluralFormCount,
+// the Catalog can still be used then.
+func (po *POFile) Catalog() (Catalog, error) {
+	catalog := mocatalog{
+		info:     make(map[string]string),
+		messages: make(map[string][]string),
+	}
+	if po.Header != nil && len(po.Header.Str) != 0 {
+		err := catalog.read_info(po.Header.Str[0])
+		if err != nil {
+			return catalog, err
+		}
+		catalog.messages[""] = po.Header.Str
+	}
+	var err error
+	for _, entry := range po.Entries {
+		if entry.Obsolete || entry.HasFlag("fuzzy") || !entry.Translated() {
+			continue
+		}
+		if entry.Plural() && err == nil {
+			err = catalog.check_forms(entry.Key(), len(entry.Str))
+		}
+		catalog.messages[entry.Key()] = entry.Str
+	}
+	return catalog, err
+}
+
+// po_parser holds the state of ParsePO while it goes through the lines.
+type po_parser struct {
+	po      *POFile
+	entry   *POEntry
+	line    int
+	field   *string // the string continuation lines are appended to
+	has_id  bool
+	has_str bool
+}
+
+// ParsePO parses a po or pot file.
+func ParsePO(r io.Reader) (*POFile, error) {
+	p := &po_parser{po: &POFile{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		p.line++
+		err := p.parse_line(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	err := p.flush()
+	if err != nil {
+		return nil, err
+	}
+	return p.po, nil
+}
+
+func (p *po_parser) error(format string, args ...interface{}) error {
+	return &SyntaxError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *po_parser) current() *POEntry {
+	if p.entry == nil {
+		p.entry = &POEntry{}
+	}
+	return p.entry
+}
+
+// flush finishes the current entry, if any.
+func (p *po_parser) flush() error {
+	entry, has_id := p.entry, p.has_id
+	p.entry, p.field, p.has_id, p.has_str = nil, nil, false, false
+	if entry == nil {
+		return nil
+	}
+	if !has_id && !entry.HasContext {
+		// Only comments, which GNU gettext tolerates at the end of a file.
+		return nil
+	}
+	if entry.Str == nil {
+		return p.error("missing msgstr for msgid %q", entry.ID)
+	}
+	if entry.ID == "" && !entry.HasContext && !entry.Obsolete && p.po.Header == nil {
+		p.po.Header = entry
+	} else {
+		p.po.Entries = append(p.po.Entries, entry)
+	}
+	return nil
+}
+
+func (p *po_parser) parse_line(line string) error {
+	if line == "" {
+		return p.flush()
+	}
+	obsolete := false
+	if strings.HasPrefix(line, "#~") {
+		obsolete = true
+		line = strings.TrimSpace(line[2:])
+		if line == "" {
+			return nil
+		}
+	}
+	if strings.HasPrefix(line, "#|") {
+		return p.parse_previous(strings.TrimSpace(line[2:]))
+	}
+	if obsolete && strings.HasPrefix(line, "|") {
+		return p.parse_previous(strings.TrimSpace(line[1:]))
+	}
+	if strings.HasPrefix(line, "#") {
+		if p.has_id {
+			err := p.flush()
+			if err != nil {
+				return err
+			}
+		}
+		return p.parse_comment(line)
+	}
+	if strings.HasPrefix(line, `"`) {
+		if p.field == nil {
+			return p.error("unexpected string continuation")
+		}
+		s, err := p.unquote(line)
+		if err != nil {
+			return err
+		}
+		*p.field += s
+		return nil
+	}
+	keyword, value, _ := strings.Cut(line, " ")
+	value = strings.TrimSpace(value)
+	if (keyword == "msgctxt" || keyword == "msgid") && p.has_str {
+		err := p.flush()
+		if err != nil {
+			return err
+		}
+	}
+	s, err := p.unquote(value)
+	if err != nil {
+		return err
+	}
+	entry := p.current()
+	if obsolete {
+		entry.Obsolete = true
+	}
+	switch {
+	case keyword == "msgctxt":
+		if p.has_id {
+			return p.error("msgctxt after msgid")
+		}
+		entry.HasContext = true
+		entry.Context = s
+		p.field = &entry.Context
+	case keyword == "msgid":
+		if p.has_id {
+			return p.error("duplicate msgid")
+		}
+		p.has_id = true
+		entry.ID = s
+		p.field = &entry.ID
+	case keyword == "msgid_plural":
+		if !p.has_id || p.has_str {
+			return p.error("msgid_plural must follow msgid")
+		}
+		entry.IDPlural = s
+		p.field = &entry.IDPlural
+	case keyword == "msgstr":
+		if !p.has_id || p.has_str {
+			return p.error("msgstr must follow msgid")
+		}
+		p.has_str = true
+		entry.Str = []string{s}
+		p.field = &entry.Str[0]
+	case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+		index, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+		if err != nil || index != len(entry.Str) {
+			return p.error("unexpected %s", keyword)
+		}
+		if !p.has_id || entry.IDPlural == "" {
+			return p.error("%s without msgid_plural", keyword)
+		}
+		p.has_str = true
+		entry.Str = append(entry.Str, s)
+		p.field = &entry.Str[index]
+	default:
+		return p.error("unknown keyword %q", keyword)
+	}
+	return nil
+}
+
+func (p *po_parser) parse_comment(line string) error {
+	entry := p.current()
+	if len(line) == 1 {
+		entry.TranslatorComments = append(entry.TranslatorComments, "")
+		return nil
+	}
+	text := strings.TrimSpace(line[2:])
+	switch line[1] {
+	case '.':
+		entry.ExtractedComments = append(entry.ExtractedComments, text)
+	case ':':
+		entry.References = append(entry.References, strings.Fields(text)...)
+	case ',':
+		for _, flag := range strings.Split(text, ",") {
+			if flag = strings.TrimSpace(flag); flag != "" {
+				entry.AddFlag(flag)
+			}
+		}
+	default:
+		entry.TranslatorComments = append(entry.TranslatorComments, strings.TrimSpace(line[1:]))
+	}
+	return nil
+}
+
+func (p *po_parser) parse_previous(line string) error {
+	if p.has_id {
+		err := p.flush()
+		if err != nil {
+			return err
+		}
+	}
+	entry := p.current()
+	keyword, value, _ := strings.Cut(line, " ")
+	var field *string
+	switch keyword {
+	case "msgctxt":
+		field = &entry.PreviousContext
+	case "msgid":
+		field = &entry.PreviousID
+	case "msgid_plural":
+		field = &entry.PreviousIDPlural
+	default:
+		if !strings.HasPrefix(line, `"`) || p.field == nil {
+			return p.error("unexpected previous message line")
+		}
+		s, err := p.unquote(line)
+		if err != nil {
+			return err
+		}
+		*p.field += s
+		return nil
+	}
+	s, err := p.unquote(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	*field = s
+	p.field = field
+	return nil
+}
+
+func (p *po_parser) unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", p.error("expected a quoted string, got %q", s)
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		if strings.Contains(s, `"`) {
+			return "", p.error("unescaped quote in string")
+		}
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return "", p.error("unescaped quote in string")
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", p.error("string ends in a backslash")
+		}
+		switch c = s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '"', '\'', '?':
+			b.WriteByte(c)
+		case 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && is_hex(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return "", p.error(`\x without hex digits`)
+			}
+			v, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			b.WriteByte(byte(v))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return "", p.error("invalid octal escape")
+			}
+			b.WriteByte(byte(v))
+			i = j - 1
+		default:
+			return "", p.error(`invalid escape sequence \%c`, c)
+		}
+	}
+	return b.String(), nil
+}
+
+func is_hex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// WriteTo writes the file in po format. Strings containing newlines are split
+// into one line per newline, like GNU gettext does.
+func (po *POFile) WriteTo(w io.Writer) (int64, error) {
+	pw := &po_writer{w: bufio.NewWriter(w)}
+	if po.Header != nil {
+		pw.entry(po.Header)
+	}
+	for _, entry := range po.Entries {
+		pw.entry(entry)
+	}
+	if pw.err == nil {
+		pw.err = pw.w.Flush()
+	}
+	return pw.n, pw.err
+}
+
+type po_writer struct {
+	w       *bufio.Writer
+	n       int64
+	err     error
+	written bool
+}
+
+func (pw *po_writer) printf(format string, args ...interface{}) {
+	if pw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(pw.w, format, args...)
+	pw.n += int64(n)
+	pw.err = err
+}
+
+func (pw *po_writer) entry(entry *POEntry) {
+	if pw.written {
+		pw.printf("\n")
+	}
+	pw.written = true
+	for _, comment := range entry.TranslatorComments {
+		if comment == "" {
+			pw.printf("#\n")
+		} else {
+			pw.printf("# %s\n", comment)
+		}
+	}
+	for _, comment := range entry.ExtractedComments {
+		pw.printf("#. %s\n", comment)
+	}
+	if len(entry.References) != 0 {
+		pw.printf("#: %s\n", strings.Join(entry.References, " "))
+	}
+	if len(entry.Flags) != 0 {
+		pw.printf("#, %s\n", strings.Join(entry.Flags, ", "))
+	}
+	prefix, previous := "", "#| "
+	if entry.Obsolete {
+		prefix, previous = "#~ ", "#~| "
+	}
+	if entry.PreviousContext != "" {
+		pw.field(previous, "msgctxt", entry.PreviousContext)
+	}
+	if entry.PreviousID != "" {
+		pw.field(previous, "msgid", entry.PreviousID)
+	}
+	if entry.PreviousIDPlural != "" {
+		pw.field(previous, "msgid_plural", entry.PreviousIDPlural)
+	}
+	if entry.HasContext {
+		pw.field(prefix, "msgctxt", entry.Context)
+	}
+	pw.field(prefix, "msgid", entry.ID)
+	if entry.Plural() {
+		pw.field(prefix, "msgid_plural", entry.IDPlural)
+		for i, s := range entry.Str {
+			pw.field(prefix, fmt.Sprintf("msgstr[%d]", i), s)
+		}
+	} else if len(entry.Str) == 0 {
+		pw.field(prefix, "msgstr", "")
+	} else {
+		pw.field(prefix, "msgstr", entry.Str[0])
+	}
+}
+
+func (pw *po_writer) field(prefix string, keyword string, value string) {
+	lines := strings.SplitAfter(value, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		pw.printf("%s%s %s\n", prefix, keyword, quote_po(value))
+		return
+	}
+	pw.printf("%s%s \"\"\n", prefix, keyword)
+	for _, line := range lines {
+		pw.printf("%s%s\n", prefix, quote_po(line))
+	}
+}
+
+var po_escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\t", `\t`,
+	"\r", `\r`,
+	"\a", `\a`,
+	"\b", `\b`,
+	"\f", `\f`,
+	"\v", `\v`,
+)
+
+func quote_po(s string) string {
+	return `"` + po_escaper.Replace(s) + `"`
+}
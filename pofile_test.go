@@ -0,0 +1,247 @@
+package gettext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const full_po = `# Translation of an example app.
+# Copyright (C) 2016 Example
+#
+#, fuzzy
+msgid ""
+msgstr ""
+"Project-Id-Version: example 1.0\n"
+"Language: de\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+# A translator comment
+#. TRANSLATORS: shown on the start page
+#: main.go:12 main.go:40
+#: web/index.go:3
+#, c-format
+msgid "Hello %s"
+msgstr "Hallo %s"
+
+msgctxt "verb"
+msgid "Open"
+msgstr "Öffnen"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] "%d Dateien"
+
+#, fuzzy
+#| msgid "Goodbye"
+msgid "Good bye"
+msgstr "Auf Wiedersehen"
+
+msgid ""
+"multi\n"
+"line"
+msgstr "mehrere\n"
+"Zeilen \"zitiert\" \t\101\x42\\"
+
+msgid "untranslated"
+msgstr ""
+
+#~ msgid "removed"
+#~ msgstr "entfernt"
+
+#~| msgid "older"
+#~ msgctxt "menu"
+#~ msgid "old"
+#~ msgstr "alt"
+`
+
+func TestParsePO(t *testing.T) {
+	po, err := ParsePO(strings.NewReader(full_po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if po.Header == nil {
+		t.Fatal("header missing")
+	}
+	if !reflect.DeepEqual(po.Header.TranslatorComments, []string{"Translation of an example app.", "Copyright (C) 2016 Example", ""}) {
+		t.Errorf("unexpected header comments %q", po.Header.TranslatorComments)
+	}
+	assert_equal(t, po.HeaderField("language"), "de")
+	assert_equal(t, po.HeaderField("Plural-Forms"), "nplurals=2; plural=(n != 1);")
+	if len(po.Entries) != 8 {
+		t.Fatalf("expected 8 entries, got %d", len(po.Entries))
+	}
+
+	hello := po.Entries[0]
+	expected := &POEntry{
+		TranslatorComments: []string{"A translator comment"},
+		ExtractedComments:  []string{"TRANSLATORS: shown on the start page"},
+		References:         []string{"main.go:12", "main.go:40", "web/index.go:3"},
+		Flags:              []string{"c-format"},
+		ID:                 "Hello %s",
+		Str:                []string{"Hallo %s"},
+	}
+	if !reflect.DeepEqual(hello, expected) {
+		t.Errorf("expected %+v, got %+v", expected, hello)
+	}
+
+	open := po.Entries[1]
+	if !open.HasContext || open.Context != "verb" || open.Key() != "verb\x04Open" {
+		t.Errorf("unexpected context entry %+v", open)
+	}
+
+	files := po.Entries[2]
+	if !files.Plural() || !reflect.DeepEqual(files.Str, []string{"%d Datei", "%d Dateien"}) {
+		t.Errorf("unexpected plural entry %+v", files)
+	}
+
+	bye := po.Entries[3]
+	if !bye.HasFlag("fuzzy") || bye.PreviousID != "Goodbye" {
+		t.Errorf("unexpected fuzzy entry %+v", bye)
+	}
+
+	multi := po.Entries[4]
+	assert_equal(t, multi.ID, "multi\nline")
+	assert_equal(t, multi.Str[0], "mehrere\nZeilen \"zitiert\" \tAB\\")
+
+	if po.Entries[5].Translated() {
+		t.Errorf("expected %q to be untranslated", po.Entries[5].ID)
+	}
+
+	removed := po.Entries[6]
+	if !removed.Obsolete || removed.ID != "removed" || removed.Str[0] != "entfernt" {
+		t.Errorf("unexpected obsolete entry %+v", removed)
+	}
+	old := po.Entries[7]
+	if !old.Obsolete || old.Context != "menu" || old.PreviousID != "older" {
+		t.Errorf("unexpected obsolete entry %+v", old)
+	}
+}
+
+func TestPOCatalog(t *testing.T) {
+	po, err := ParsePO(strings.NewReader(full_po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := po.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, catalog.Gettext("Hello %s"), "Hallo %s")
+	assert_equal(t, catalog.PGettext("verb", "Open"), "Öffnen")
+	assert_equal(t, catalog.Gettext("Open"), "Open")
+	assert_equal(t, fmt.Sprintf(catalog.NGettext("%d file", "%d files", 1), 1), "1 Datei")
+	assert_equal(t, fmt.Sprintf(catalog.NGettext("%d file", "%d files", 3), 3), "3 Dateien")
+	// fuzzy, untranslated and obsolete entries are not used
+	assert_equal(t, catalog.Gettext("Good bye"), "Good bye")
+	assert_equal(t, catalog.Gettext("untranslated"), "untranslated")
+	assert_equal(t, catalog.Gettext("removed"), "removed")
+}
+
+func TestPOCatalogMatchesMO(t *testing.T) {
+	for _, locale := range []string{"en", "ja", "fr", "en-no-plural-forms"} {
+		f, err := os.Open("testdata/" + locale + "/messages.po")
+		if err != nil {
+			t.Fatal(err)
+		}
+		po, err := ParsePO(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		from_po, err := po.Catalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile("testdata/" + locale + "/messages.mo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		from_mo, err := ParseMOBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(from_po.(mocatalog).messages, from_mo.(mocatalog).messages) {
+			t.Errorf("%s: po and mo catalogs differ", locale)
+		}
+		for n := uint32(0); n < 3; n++ {
+			assert_equal(t,
+				from_po.NGettext("order %d beer", "order %d beers", n),
+				from_mo.NGettext("order %d beer", "order %d beers", n),
+			)
+		}
+	}
+}
+
+func TestWritePORoundTrip(t *testing.T) {
+	for _, locale := range []string{"en", "ja", "fr"} {
+		data, err := os.ReadFile("testdata/" + locale + "/messages.po")
+		if err != nil {
+			t.Fatal(err)
+		}
+		po, err := ParsePO(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		_, err = po.WriteTo(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert_equal(t, out.String(), string(data))
+	}
+	po, err := ParsePO(strings.NewReader(full_po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	_, err = po.WriteTo(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParsePO(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(po, again) {
+		t.Errorf("po file changed after writing and parsing it again")
+	}
+}
+
+func TestSetHeaderField(t *testing.T) {
+	po := &POFile{}
+	po.SetHeaderField("Language", "de")
+	po.SetHeaderField("Content-Type", "text/plain; charset=UTF-8")
+	po.SetHeaderField("language", "fr")
+	assert_equal(t, po.Header.Str[0], "language: fr\nContent-Type: text/plain; charset=UTF-8\n")
+	assert_equal(t, po.HeaderField("Language"), "fr")
+}
+
+func TestParsePOErrors(t *testing.T) {
+	for _, source := range []string{
+		"msgid \"a\"\n",
+		"msgstr \"a\"\n",
+		"msgid \"a\"\nmsgid \"b\"\nmsgstr \"\"\n",
+		"msgid \"a\nmsgstr \"\"\n",
+		"msgid \"a\"\nmsgstr \"\\q\"\n",
+		"msgid \"a\"\nmsgstr[0] \"b\"\n",
+		"msgid \"a\"\nmsgid_plural \"b\"\nmsgstr[1] \"b\"\n",
+		"\"dangling\"\n",
+		"msgfoo \"a\"\n",
+		"msgid \"\"\n",
+		"# header\nmsgid \"\"\n\nmsgid \"a\"\nmsgstr \"b\"\n",
+		"msgid \"a\"\nmsgstr \"b\"\n\n#, fuzzy\nmsgid \"\"\n",
+	} {
+		_, err := ParsePO(strings.NewReader(source))
+		var syntax_error *SyntaxError
+		if !errors.As(err, &syntax_error) || !errors.Is(err, ErrCorruptCatalog) {
+			t.Errorf("%q: expected syntax error, got %v", source, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package gettext
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// catalog_state is a loaded catalog along with what the mo file looked like
+// when it was read, to tell whether it changed since.
+type catalog_state struct {
+	catalog Catalog
+	err     error
+	size    int64
+	modtime time.Time
+	hash    [sha256.Size]byte
+	hashed  bool
+}
+
+// Reload reads the catalogs of locale and its fallback locales again, in all
+// domains loaded so far. Catalogs already returned by Locale keep their
+// translations, call Locale again to see the new ones. If a mo file cannot be
+// loaded, the catalog loaded before stays in use and the error is returned.
+// A mo file that was removed makes the locale unavailable.
+func (t Translations) Reload(locale string) error {
+	candidates := t.candidates(locale)
+	return t.reload(func(key cache_key, entry *cache_entry) bool {
+		return contains(candidates, key.locale)
+	})
+}
+
+// ReloadAll is like Reload, but for all locales loaded so far.
+func (t Translations) ReloadAll() error {
+	return t.reload(func(key cache_key, entry *cache_entry) bool {
+		return true
+	})
+}
+
+// ReloadChanged is like ReloadAll, but only reads the mo files whose size or
+// modification time changed since they were loaded, and only parses those
+// whose content changed. Memory mapped files, see WithLazyCatalogs, are not
+// hashed and are parsed again whenever they were read.
+func (t Translations) ReloadChanged() error {
+	return t.reload(t.changed)
+}
+
+func (t Translations) reload(filter func(key cache_key, entry *cache_entry) bool) error {
+	var errs []error
+	for key, entry := range t.cache.all() {
+		t.current(entry, key)
+		if !filter(key, entry) {
+			continue
+		}
+		if err := t.swap(key, entry); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// changed tells whether the mo file of entry looks different from when it was
+// loaded.
+func (t Translations) changed(key cache_key, entry *cache_entry) bool {
+	state := entry.state.Load()
+	info, err := fs.Stat(t.fsys, t.resolver(t.root, key.locale, key.domain))
+	if err != nil {
+		return state.err == nil
+	}
+	return state.err != nil || info.Size() != state.size || !info.ModTime().Equal(state.modtime)
+}
+
+// swap loads the catalog of entry again and makes it the current one, unless
+// the new catalog is broken while the old one was not. An unchanged mo file
+// keeps its catalog.
+func (t Translations) swap(key cache_key, entry *cache_entry) error {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	old := entry.state.Load()
+	state := t.load(key.locale, key.domain, old)
+	if !usable(state.err) && usable(old.err) && !errors.Is(state.err, ErrNotFound) {
+		return state.err
+	}
+	entry.state.Store(state)
+	return state.err
+}
+
+// Watcher polls the mo files of a Translations for changes, see Watch.
+type Watcher struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// The shortest interval Watch polls at.
+const min_watch_interval = time.Millisecond
+
+// Watch calls ReloadChanged every interval until the returned Watcher is
+// stopped. Errors are passed to on_error, which may be nil. Intervals shorter
+// than a millisecond, including zero and negative ones, are raised to a
+// millisecond.
+func (t Translations) Watch(interval time.Duration, on_error func(err error)) *Watcher {
+	if interval < min_watch_interval {
+		interval = min_watch_interval
+	}
+	w := &Watcher{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if err := t.ReloadChanged(); err != nil && on_error != nil {
+					on_error(err)
+				}
+			}
+		}
+	}()
+	return w
+}
+
+// Stop stops polling and waits for a check in progress to finish.
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
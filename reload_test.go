@@ -0,0 +1,178 @@
+package gettext
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func read_testdata(t *testing.T, locale string) []byte {
+	data, err := os.ReadFile("testdata/" + locale + "/messages.mo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReload(t *testing.T) {
+	mapfs := fstest.MapFS{
+		"xx/messages.mo": &fstest.MapFile{Data: read_testdata(t, "en_GB")},
+	}
+	translations := NewTranslationsFS(mapfs, "messages", my_resolver)
+	before := translations.Locale("xx")
+	assert_equal(t, before.Gettext("greeting"), "Good day")
+
+	mapfs["xx/messages.mo"] = &fstest.MapFile{Data: read_testdata(t, "fr")}
+	assert_equal(t, translations.Locale("xx").Gettext("greeting"), "Good day")
+	if err := translations.Reload("xx_YY"); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, translations.Locale("xx").Gettext("greeting"), "Bonjour")
+	assert_equal(t, before.Gettext("greeting"), "Good day")
+
+	mapfs["xx/messages.mo"] = &fstest.MapFile{Data: []byte("garbage")}
+	err := translations.ReloadAll()
+	if !errors.Is(err, ErrCorruptCatalog) {
+		t.Fatalf("expected a corrupt catalog error, got %v", err)
+	}
+	assert_equal(t, translations.Locale("xx").Gettext("greeting"), "Bonjour")
+
+	delete(mapfs, "xx/messages.mo")
+	if err := translations.ReloadAll(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = translations.LocaleE("xx")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the removed catalog to be not found, got %v", err)
+	}
+}
+
+func TestReloadChanged(t *testing.T) {
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	en_gb := read_testdata(t, "en_GB")
+	mapfs := fstest.MapFS{
+		"xx/messages.mo": &fstest.MapFile{Data: en_gb, ModTime: modtime},
+	}
+	translations := NewTranslationsFS(mapfs, "messages", my_resolver)
+	translations.Preload("xx", "yy")
+	catalog, _ := translations.get("xx", "messages")
+
+	// Same content with a new modification time is read but not parsed.
+	mapfs["xx/messages.mo"] = &fstest.MapFile{Data: en_gb, ModTime: modtime.Add(time.Second)}
+	if err := translations.ReloadChanged(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _ := translations.get("xx", "messages")
+	if reflect.ValueOf(reloaded.(mocatalog).messages).Pointer() != reflect.ValueOf(catalog.(mocatalog).messages).Pointer() {
+		t.Error("expected an unchanged mo file to keep its catalog")
+	}
+
+	// Matching content is not parsed at all.
+	marker := mocatalog{language: "marker"}
+	state := translations.load("xx", "messages", &catalog_state{catalog: marker, hash: sha256.Sum256(en_gb), hashed: true})
+	if state.err != nil || state.catalog.Language() != "marker" {
+		t.Errorf("expected the old catalog to be reused, got %v, %v", state.catalog.Language(), state.err)
+	}
+	state = translations.load("xx", "messages", &catalog_state{catalog: marker, hash: sha256.Sum256(nil), hashed: true})
+	if state.err != nil || state.catalog.Language() != "en_GB" {
+		t.Errorf("expected changed content to be parsed, got %v, %v", state.catalog.Language(), state.err)
+	}
+
+	// New content with the same size and modification time goes unnoticed.
+	changed := append([]byte{}, en_gb...)
+	copy(changed[len(changed)-9:], "Good bye\x00")
+	mapfs["xx/messages.mo"] = &fstest.MapFile{Data: changed, ModTime: modtime.Add(time.Second)}
+	if err := translations.ReloadChanged(); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, translations.Locale("xx").Gettext("greeting"), "Good day")
+	if err := translations.Reload("xx"); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, translations.Locale("xx").Gettext("greeting"), "Good bye")
+
+	// Locales that were not found are picked up once their mo file appears.
+	mapfs["yy/messages.mo"] = &fstest.MapFile{Data: read_testdata(t, "fr")}
+	if err := translations.ReloadChanged(); err != nil {
+		t.Fatal(err)
+	}
+	assert_equal(t, translations.Locale("yy").Gettext("greeting"), "Bonjour")
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xx", "messages.mo")
+	if err := os.Mkdir(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, read_testdata(t, "en_GB"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	translations := NewTranslations(dir, "messages", my_resolver)
+	assert_equal(t, translations.Locale("xx").Gettext("greeting"), "Good day")
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				greeting := translations.Locale("xx").Gettext("greeting")
+				if greeting != "Good day" && greeting != "Bonjour" {
+					t.Errorf("unexpected greeting %q", greeting)
+					return
+				}
+			}
+		}()
+	}
+
+	watcher := translations.Watch(time.Millisecond, func(err error) {
+		t.Error(err)
+	})
+	defer watcher.Stop()
+	if err := os.WriteFile(path, read_testdata(t, "fr"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for translations.Locale("xx").Gettext("greeting") != "Bonjour" {
+		if time.Now().After(deadline) {
+			t.Fatal("the changed mo file was not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+	watcher.Stop()
+}
+
+func TestWatchInterval(t *testing.T) {
+	for _, interval := range []time.Duration{-time.Second, 0, time.Nanosecond} {
+		mapfs := fstest.MapFS{
+			"xx/messages.mo": &fstest.MapFile{Data: read_testdata(t, "en_GB")},
+		}
+		translations := NewTranslationsFS(mapfs, "messages", my_resolver)
+		assert_equal(t, translations.Locale("xx").Gettext("greeting"), "Good day")
+		mapfs["xx/messages.mo"] = &fstest.MapFile{Data: read_testdata(t, "fr")}
+		watcher := translations.Watch(interval, nil)
+		deadline := time.Now().Add(5 * time.Second)
+		for translations.Locale("xx").Gettext("greeting") != "Bonjour" {
+			if time.Now().After(deadline) {
+				t.Fatalf("interval %v: the changed mo file was not reloaded", interval)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		watcher.Stop()
+	}
+}
@@ -0,0 +1,174 @@
+package gettext
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Marks the last segment of a system dependent string.
+const sysdep_segments_end = 0xffffffff
+
+// sysdep_segment returns what a system dependent segment stands for in a Go
+// format string. Segments are the names of <inttypes.h> format macros such as
+// "PRIu64", which msgfmt writes for "%<PRIu64>", and the "I" flag of glibc,
+// which has no equivalent in Go.
+func sysdep_segment(name string) (string, bool) {
+	if name == "I" {
+		return "", true
+	}
+	if len(name) < 5 || !strings.HasPrefix(name, "PRI") {
+		return "", false
+	}
+	switch name[4:] {
+	case "8", "16", "32", "64", "MAX", "PTR",
+		"LEAST8", "LEAST16", "LEAST32", "LEAST64",
+		"FAST8", "FAST16", "FAST32", "FAST64":
+	default:
+		return "", false
+	}
+	switch name[3] {
+	case 'd', 'i', 'u':
+		return "d", true
+	case 'o', 'x', 'X':
+		return name[3:4], true
+	}
+	return "", false
+}
+
+// sysdep_placeholder returns how po files write a system dependent segment,
+// "<PRIu64>" for PRIu64, so that msgfmt compiles decompiled strings again.
+func sysdep_placeholder(name string) (string, bool) {
+	return "<" + name + ">", true
+}
+
+type sysdep_value struct {
+	value string
+	ok    bool
+}
+
+// sysdep_message is a system dependent message expanded for Go. It is ok if
+// all of its segments are known.
+type sysdep_message struct {
+	mo_message
+	ok bool
+}
+
+// read_sysdep reads the system dependent strings of a revision 1 mo file and
+// expands their segments with segment, sysdep_segment for Go. Messages using
+// segments that are not known are left out, like GNU gettext does. The static
+// pieces of the strings are taken from budget.
+func read_sysdep(file *io.SectionReader, order binary.ByteOrder, budget *decode_budget, segment func(name string) (string, bool)) ([]mo_message, error) {
+	sysdep, err := read_sysdep_messages(file, order, budget, segment)
+	messages := []mo_message{}
+	for _, message := range sysdep {
+		if message.ok {
+			messages = append(messages, message.mo_message)
+		}
+	}
+	return messages, err
+}
+
+// read_sysdep_messages is read_sysdep keeping all messages, in the order of
+// the file.
+func read_sysdep_messages(file *io.SectionReader, order binary.ByteOrder, budget *decode_budget, segment func(name string) (string, bool)) ([]sysdep_message, error) {
+	messages := []sysdep_message{}
+	fields := make([]uint32, 5)
+	buf := make([]byte, 4*len(fields))
+	err := read_at(file, buf, 28)
+	if err != nil {
+		return messages, err
+	}
+	for i := range fields {
+		fields[i] = order.Uint32(buf[4*i:])
+	}
+	n_segments, segments_offset := fields[0], fields[1]
+	n_strings, orig_offset, trans_offset := fields[2], fields[3], fields[4]
+	size := uint64(file.Size())
+	if uint64(segments_offset)+8*uint64(n_segments) > size ||
+		uint64(orig_offset)+4*uint64(n_strings) > size ||
+		uint64(trans_offset)+4*uint64(n_strings) > size {
+		return messages, errors.New("system dependent tables exceed the file size")
+	}
+	segments := make([]sysdep_value, n_segments)
+	for i := range segments {
+		lenoff, err := read_len_off(segments_offset+8*uint32(i), file, order)
+		if err != nil {
+			return messages, err
+		}
+		if !lenoff_within(lenoff, file.Size()) {
+			return messages, fmt.Errorf("system dependent segment %d exceeds the file size", i)
+		}
+		name, err := read_message(file, lenoff)
+		if err != nil {
+			return messages, err
+		}
+		segments[i].value, segments[i].ok = segment(strings.TrimSuffix(name, "\x00"))
+	}
+	for i := uint32(0); i < n_strings; i++ {
+		key, key_ok, err := read_sysdep_string(file, order, orig_offset+4*i, segments, budget)
+		if err != nil {
+			return messages, err
+		}
+		str, str_ok, err := read_sysdep_string(file, order, trans_offset+4*i, segments, budget)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, sysdep_message{mo_message{key: key, str: str}, key_ok && str_ok})
+	}
+	return messages, nil
+}
+
+// read_sysdep_string expands the system dependent string whose description
+// is referenced at index. The description holds the offset of the static
+// pieces of the string, stored one after the other, and then pairs of the size
+// of a static piece and the segment that follows it.
+func read_sysdep_string(file *io.SectionReader, order binary.ByteOrder, index uint32, segments []sysdep_value, budget *decode_budget) (string, bool, error) {
+	buf := make([]byte, 8)
+	err := read_at(file, buf[:4], int64(index))
+	if err != nil {
+		return "", false, err
+	}
+	description := int64(order.Uint32(buf[:4]))
+	err = read_at(file, buf[:4], description)
+	if err != nil {
+		return "", false, err
+	}
+	static := uint64(order.Uint32(buf[:4]))
+	var b strings.Builder
+	ok := true
+	for pair := description + 4; ; pair += 8 {
+		err = read_at(file, buf, pair)
+		if err != nil {
+			return "", false, err
+		}
+		segsize, ref := order.Uint32(buf[:4]), order.Uint32(buf[4:])
+		if static+uint64(segsize) > uint64(file.Size()) {
+			return "", false, errors.New("system dependent string exceeds the file size")
+		}
+		if err := budget.take(segsize); err != nil {
+			return "", false, err
+		}
+		piece, err := read_message(file, len_offset{Len: segsize, Off: uint32(static)})
+		if err != nil {
+			return "", false, err
+		}
+		b.WriteString(piece)
+		static += uint64(segsize)
+		if ref == sysdep_segments_end {
+			break
+		}
+		if ref >= uint32(len(segments)) {
+			return "", false, fmt.Errorf("system dependent segment %d out of range", ref)
+		}
+		// At least a byte per segment, so shared descriptions are bounded too
+		if err := budget.take(uint32(len(segments[ref].value)) + 1); err != nil {
+			return "", false, err
+		}
+		b.WriteString(segments[ref].value)
+		ok = ok && segments[ref].ok
+	}
+	return strings.TrimSuffix(b.String(), "\x00"), ok, nil
+}
@@ -0,0 +1,173 @@
+package gettext
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSysdepStrings(t *testing.T) {
+	// Generated by gensysdep.py
+	data := read_testdata(t, "sysdep")
+	for name, parse := range map[string]func([]byte) (Catalog, error){
+		"ParseMOBytes": ParseMOBytes,
+		"ParseMOLazy":  ParseMOLazy,
+	} {
+		catalog, err := parse(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		assert_equal(t, catalog.Gettext("greeting"), "Hallo")
+		assert_equal(t, fmt.Sprintf(catalog.NGettext("%d file", "%d files", 1), 1), "1 Datei")
+		assert_equal(t, fmt.Sprintf(catalog.NGettext("%d file", "%d files", 3), 3), "3 Dateien")
+		assert_equal(t, catalog.PGettext("progress", "%d%% done"), "%d%% fertig")
+		assert_equal(t, catalog.Gettext("%d apples"), "%d Äpfel")
+		assert_equal(t, catalog.Gettext("address %#x"), "Adresse %#x")
+		assert_equal(t, catalog.Gettext("%d of %d"), "%d von %d")
+		if _, ok := catalog.Lookup("%<PRIq64> unknown"); ok {
+			t.Errorf("%s: expected strings with unknown segments to be left out", name)
+		}
+		assert_equal(t, catalog.Language(), "de")
+	}
+}
+
+func TestSysdepHashTable(t *testing.T) {
+	// Hash table entries past the static messages refer to system dependent
+	// messages. Put one where "greeting" is looked for first, so its lookup
+	// has to probe past it.
+	data := read_testdata(t, "sysdep")
+	table, _, err := new_mo_table(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash_table := make([]uint32, table.hash_size)
+	insert := func(key string, nstr uint32) {
+		hash := hash_string(key)
+		index := hash % table.hash_size
+		incr := 1 + hash%(table.hash_size-2)
+		for hash_table[index] != 0 {
+			if index >= table.hash_size-incr {
+				index -= table.hash_size - incr
+			} else {
+				index += incr
+			}
+		}
+		hash_table[index] = nstr
+	}
+	hash_table[hash_string("greeting")%table.hash_size] = table.n + 1
+	for i, key := range table.sysdep_keys[1:] {
+		msgid, _, _ := strings.Cut(key, "\x00")
+		insert(msgid, table.n+uint32(i)+2)
+	}
+	for i := uint32(0); i < table.n; i++ {
+		insert(string(table.msgid(i)), i+1)
+	}
+	for i, nstr := range hash_table {
+		binary.LittleEndian.PutUint32(data[table.hash_index+4*uint32(i):], nstr)
+	}
+	eager, err := ParseMOBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := ParseMOLazy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The expanded keys, DecompileMO keeps the segments
+	po := &POFile{}
+	for _, key := range append([]string{"greeting"}, table.sysdep_keys...) {
+		entry := &POEntry{}
+		if context, msgid, ok := strings.Cut(key, context_separator); ok {
+			entry.HasContext, entry.Context, key = true, context, msgid
+		}
+		entry.ID, entry.IDPlural, _ = strings.Cut(key, "\x00")
+		po.Entries = append(po.Entries, entry)
+	}
+	assert_same_catalog(t, "sysdep", po, eager, lazy)
+	assert_equal(t, lazy.Gettext("greeting"), "Hallo")
+	assert_equal(t, lazy.Gettext("%d of %d"), "%d von %d")
+}
+
+func TestDecompileSysdep(t *testing.T) {
+	po, err := DecompileMO(read_testdata(t, "sysdep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Like msgunfmt, strings with unknown segments are kept too
+	if len(po.Entries) != 7 {
+		t.Fatalf("expected 7 entries, got %d", len(po.Entries))
+	}
+	assert_equal(t, po.Entries[0].ID, "greeting")
+	if po.Entries[0].HasFlag("c-format") {
+		t.Error("expected greeting not to be flagged c-format")
+	}
+	entry := po.Entries[1]
+	assert_equal(t, entry.ID, "%<PRIu64> file")
+	assert_equal(t, entry.IDPlural, "%<PRIu64> files")
+	assert_equal(t, entry.Str[1], "%<PRIu64> Dateien")
+	if !entry.HasFlag("c-format") {
+		t.Error("expected system dependent strings to be flagged c-format")
+	}
+	assert_equal(t, po.Entries[3].ID, "%<I>d apples")
+	assert_equal(t, po.Entries[4].ID, "address %#<PRIxPTR>")
+	assert_equal(t, po.Entries[6].Str[0], "%<PRIq64> unbekannt")
+}
+
+func TestSysdepSegment(t *testing.T) {
+	for name, expected := range map[string]string{
+		"I":          "",
+		"PRId64":     "d",
+		"PRIi32":     "d",
+		"PRIuMAX":    "d",
+		"PRIxPTR":    "x",
+		"PRIXFAST16": "X",
+		"PRIoLEAST8": "o",
+		"PRIu128":    "!",
+		"PRIq64":     "!",
+		"SCNd64":     "!",
+		"PRI":        "!",
+	} {
+		value, ok := sysdep_segment(name)
+		if !ok {
+			value = "!"
+		}
+		assert_equal(t, value, expected)
+	}
+}
+
+func TestSysdepCorrupt(t *testing.T) {
+	data := read_testdata(t, "sysdep")
+	for _, field := range []int{28, 32, 36, 40, 44} {
+		broken := append([]byte{}, data...)
+		binary.LittleEndian.PutUint32(broken[field:], 0xfffffff0)
+		for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+			if _, err := parse(broken); !errors.Is(err, ErrCorruptCatalog) {
+				t.Errorf("field at %d: expected a corrupt catalog error, got %v", field, err)
+			}
+		}
+	}
+}
+
+func TestSysdepSharedStrings(t *testing.T) {
+	// All system dependent strings share one description with a large static
+	// piece.
+	const strings, length = 1024, 32768
+	description := 48 + 8*strings
+	data := make([]byte, description+12+length)
+	for i, v := range []int{le_magic, 1 << 16, 0, 48, 48, 0, 0, 0, 48, strings, 48, 48 + 4*strings} {
+		binary.LittleEndian.PutUint32(data[4*i:], uint32(v))
+	}
+	for i := 0; i < 2*strings; i++ {
+		binary.LittleEndian.PutUint32(data[48+4*i:], uint32(description))
+	}
+	binary.LittleEndian.PutUint32(data[description:], uint32(description+12))
+	binary.LittleEndian.PutUint32(data[description+4:], length)
+	binary.LittleEndian.PutUint32(data[description+8:], sysdep_segments_end)
+	for _, parse := range []func([]byte) (Catalog, error){ParseMOBytes, ParseMOLazy} {
+		if _, err := parse(data); !errors.Is(err, ErrCorruptCatalog) {
+			t.Errorf("expected a corrupt catalog error, got %v", err)
+		}
+	}
+}